@@ -1,23 +1,33 @@
 package main
 
 import (
+	"crypto/subtle"
+	"encoding/json"
 	"fmt"
 	"html/template"
+	"log"
 	"net/http"
+	"time"
+
+	"github.com/interyx/chirpy/internal/auth"
+	"github.com/interyx/chirpy/internal/database"
+	"github.com/interyx/chirpy/internal/profanity"
 )
 
 func (cfg *apiConfig) writeCountHandler(w http.ResponseWriter, req *http.Request) {
-	w.Header().Add("Content-Type", "text/html; charset=utf-8")
-	w.WriteHeader(200)
 	tmpl, err := template.ParseFiles("hits.html")
 	if err != nil {
-		fmt.Printf("An error has occured: %v\n", err)
+		log.Printf("An error has occured: %v\n", err)
+		respondWithError(w, 500, errCodeInternal, "An error occurred loading the metrics page")
+		return
 	}
 	data := struct {
 		Hits int32
 	}{
 		Hits: cfg.fileserverHits.Load(),
 	}
+	w.Header().Add("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(200)
 	err = tmpl.Execute(w, data)
 	if err != nil {
 		fmt.Printf("An error has occurred: %v\n", err)
@@ -25,19 +35,131 @@ func (cfg *apiConfig) writeCountHandler(w http.ResponseWriter, req *http.Request
 }
 
 func (cfg *apiConfig) resetHandler(w http.ResponseWriter, req *http.Request) {
-	w.Header().Add("Content-Type", "text/plain; charset=utf-8")
 	if cfg.platform != "dev" {
-		w.WriteHeader(400)
-		fmt.Fprintf(w, "This action requires administrator approval.  Nothing has changed.")
+		respondWithError(w, 400, errCodeForbidden, "This action requires administrator approval.  Nothing has changed.")
 		return
 	}
 	err := cfg.db.DeleteUsers(req.Context())
 	if err != nil {
-		w.WriteHeader(500)
-		fmt.Fprintf(w, "An internal server error occurred while deleting all users: %s", err)
+		msg := fmt.Sprintf("An internal server error occurred while deleting all users: %s", err)
+		respondWithError(w, 500, errCodeInternal, msg)
 		return
 	}
+	w.Header().Add("Content-Type", "text/plain; charset=utf-8")
 	w.WriteHeader(200)
 	cfg.fileserverHits.Store(0)
 	fmt.Fprintf(w, "OK")
 }
+
+// requireAdmin checks the ADMIN_KEY API key and writes a 401 when it is
+// missing or wrong.
+func (cfg *apiConfig) requireAdmin(w http.ResponseWriter, req *http.Request) bool {
+	key, err := auth.GetAPIKey(req.Header)
+	if err != nil || cfg.adminKey == "" || subtle.ConstantTimeCompare([]byte(key), []byte(cfg.adminKey)) != 1 {
+		respondWithError(w, 401, errCodeInvalidAPIKey, "Invalid API key")
+		return false
+	}
+	return true
+}
+
+func (cfg *apiConfig) getBannedWordsHandler(w http.ResponseWriter, req *http.Request) {
+	type outerface struct {
+		Words []string `json:"words"`
+	}
+	if !cfg.requireAdmin(w, req) {
+		return
+	}
+	out, err := json.Marshal(outerface{Words: cfg.profanity.Words()})
+	if err != nil {
+		respondWithError(w, 500, errCodeInternal, "A marshaling error occurred")
+		return
+	}
+	respondWithJSON(w, 200, out)
+}
+
+func (cfg *apiConfig) addBannedWordHandler(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Word string `json:"word"`
+	}
+	if !cfg.requireAdmin(w, req) {
+		return
+	}
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	if err := decoder.Decode(&params); err != nil {
+		msg := fmt.Sprintf("An error occurred marshaling JSON: %s", err)
+		respondWithError(w, 400, errCodeInvalidJSON, msg)
+		return
+	}
+	word := profanity.Normalize(params.Word)
+	if word == "" {
+		respondWithError(w, 400, errCodeInvalidWord, "A banned word must be a single word")
+		return
+	}
+	wordParams := database.AddBannedWordParams{
+		Word:      word,
+		CreatedAt: time.Now(),
+	}
+	rows, err := cfg.db.AddBannedWord(req.Context(), wordParams)
+	if err != nil {
+		msg := fmt.Sprintf("An error occurred saving the banned word: %s", err)
+		respondWithError(w, 500, errCodeInternal, msg)
+		return
+	}
+	err = cfg.reloadBannedWords(req.Context())
+	if err != nil {
+		msg := fmt.Sprintf("An error occurred reloading banned words: %s", err)
+		respondWithError(w, 500, errCodeInternal, msg)
+		return
+	}
+	if rows == 0 {
+		w.WriteHeader(204)
+		return
+	}
+	w.WriteHeader(201)
+}
+
+func (cfg *apiConfig) deleteBannedWordHandler(w http.ResponseWriter, req *http.Request) {
+	if !cfg.requireAdmin(w, req) {
+		return
+	}
+	word := profanity.Normalize(req.PathValue("word"))
+	rows, err := cfg.db.DeleteBannedWord(req.Context(), word)
+	if err != nil {
+		msg := fmt.Sprintf("An error occurred deleting the banned word: %s", err)
+		respondWithError(w, 500, errCodeInternal, msg)
+		return
+	}
+	if rows == 0 {
+		respondWithError(w, 404, errCodeWordNotFound, "That word is not in the banned_words table")
+		return
+	}
+	err = cfg.reloadBannedWords(req.Context())
+	if err != nil {
+		msg := fmt.Sprintf("An error occurred reloading banned words: %s", err)
+		respondWithError(w, 500, errCodeInternal, msg)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) getFlaggedChirpsHandler(w http.ResponseWriter, req *http.Request) {
+	if !cfg.requireAdmin(w, req) {
+		return
+	}
+	flagged, err := cfg.db.GetFlaggedChirps(req.Context())
+	if err != nil {
+		msg := fmt.Sprintf("An error occurred retrieving flagged chirps: %s", err)
+		respondWithError(w, 500, errCodeInternal, msg)
+		return
+	}
+	if flagged == nil {
+		flagged = []database.GetFlaggedChirpsRow{}
+	}
+	out, err := json.Marshal(flagged)
+	if err != nil {
+		respondWithError(w, 500, errCodeInternal, "A marshaling error occurred")
+		return
+	}
+	respondWithJSON(w, 200, out)
+}
@@ -1,14 +1,29 @@
 package main
 
 import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 	"github.com/interyx/chirpy/internal/auth"
 	"github.com/interyx/chirpy/internal/database"
+	"github.com/interyx/chirpy/internal/handle"
+	"github.com/lib/pq"
+)
+
+const refreshTokenLifetime = 60 * 24 * time.Hour
+
+const (
+	maxDisplayNameLength = 50
+	maxBioLength         = 160
 )
 
 func readyHandler(w http.ResponseWriter, req *http.Request) {
@@ -16,36 +31,83 @@ func readyHandler(w http.ResponseWriter, req *http.Request) {
 	w.WriteHeader(200)
 	_, err := w.Write([]byte("OK"))
 	if err != nil {
-		msg := fmt.Sprintf("An error has occurred: %v\n", err)
-		respondWithError(w, 500, msg)
+		log.Printf("An error has occurred: %v\n", err)
+	}
+}
+
+// authenticate validates the bearer JWT on a request and returns the user
+// it was issued to.  On failure it writes a 401 and returns false.
+func (cfg *apiConfig) authenticate(w http.ResponseWriter, req *http.Request) (uuid.UUID, bool) {
+	token, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, 401, errCodeUnauthorized, "Missing access token")
+		return uuid.Nil, false
+	}
+	userID, err := auth.ValidateJWT(token, cfg.signJWT)
+	if err != nil {
+		respondWithError(w, 401, errCodeUnauthorized, "Invalid access token")
+		return uuid.Nil, false
+	}
+	return userID, true
+}
+
+// userConflict reports whether err is a unique violation on the email or
+// handle of a user, and which error code describes it.
+func userConflict(err error) (string, string, bool) {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr.Code != "23505" {
+		return "", "", false
+	}
+	switch pqErr.Constraint {
+	case "users_email_key":
+		return errCodeEmailTaken, "That email is already registered", true
+	case "users_handle_lower_idx":
+		return errCodeHandleTaken, "That handle is already taken", true
 	}
+	return "", "", false
 }
 
 func (cfg *apiConfig) addUser(w http.ResponseWriter, req *http.Request) {
 	type parameters struct {
 		Email    string `json:"email"`
 		Password string `json:"password"`
+		Handle   string `json:"handle"`
 	}
 
 	type returnVals struct {
-		Id        uuid.UUID `json:"id"`
-		CreatedAt time.Time `json:"created_at"`
-		UpdatedAt time.Time `json:"updated_at"`
-		Email     string    `json:"email"`
+		Id            uuid.UUID `json:"id"`
+		CreatedAt     time.Time `json:"created_at"`
+		UpdatedAt     time.Time `json:"updated_at"`
+		Email         string    `json:"email"`
+		IsChirpyRed   bool      `json:"is_chirpy_red"`
+		EmailVerified bool      `json:"email_verified"`
+		Handle        string    `json:"handle"`
+		DisplayName   string    `json:"display_name"`
+		Bio           string    `json:"bio"`
 	}
 	decoder := json.NewDecoder(req.Body)
 	params := parameters{}
 	if err := decoder.Decode(&params); err != nil {
 		msg := fmt.Sprintf("An error occurred marshaling JSON: %s", err)
-		respondWithError(w, 400, msg)
+		respondWithError(w, 400, errCodeInvalidJSON, msg)
 		return
 	}
+	if !validEmail(params.Email) {
+		respondWithError(w, 400, errCodeInvalidEmail, "A valid email address is required")
+		return
+	}
+	if params.Handle != "" {
+		if err := handle.Validate(params.Handle); err != nil {
+			respondWithError(w, 400, errCodeInvalidHandle, err.Error())
+			return
+		}
+	}
 
 	safePassword, err := auth.HashPassword(params.Password)
 
 	if err != nil {
 		msg := fmt.Sprintf("An error occurred generating a password: %s", err)
-		respondWithError(w, 500, msg)
+		respondWithError(w, 500, errCodeInternal, msg)
 		return
 	}
 
@@ -55,90 +117,550 @@ func (cfg *apiConfig) addUser(w http.ResponseWriter, req *http.Request) {
 		UpdatedAt:      time.Now(),
 		Email:          params.Email,
 		HashedPassword: safePassword,
+		Handle:         sql.NullString{String: params.Handle, Valid: params.Handle != ""},
 	}
 	user, err := cfg.db.CreateUser(req.Context(), userParameters)
+	if code, msg, conflict := userConflict(err); conflict {
+		respondWithError(w, 409, code, msg)
+		return
+	}
 	if err != nil {
 		msg := fmt.Sprintf("An error occurred inserting the user into the database: %s", err)
-		respondWithError(w, 500, msg)
+		respondWithError(w, 500, errCodeInternal, msg)
 		return
 	}
+	// The account exists either way; a lost email can be sent again.
+	if err := cfg.sendVerification(req.Context(), user); err != nil {
+		log.Printf("An error occurred sending the verification email: %s", err)
+	}
 	respBody := returnVals{
-		Id:        user.ID,
-		CreatedAt: user.CreatedAt,
-		UpdatedAt: user.UpdatedAt,
-		Email:     user.Email,
+		Id:            user.ID,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+		Email:         user.Email,
+		IsChirpyRed:   user.IsChirpyRed,
+		EmailVerified: user.EmailVerified,
+		Handle:        user.Handle.String,
+		DisplayName:   user.DisplayName,
+		Bio:           user.Bio,
 	}
 	out, err := json.Marshal(respBody)
 	if err != nil {
 		msg := fmt.Sprintf("An error occurred marshaling the JSON data: %s\n", err)
-		respondWithError(w, 500, msg)
+		respondWithError(w, 500, errCodeInternal, msg)
 		return
 	}
 	respondWithJSON(w, 201, out)
 }
 
+func (cfg *apiConfig) updateUser(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Email       string  `json:"email"`
+		Password    string  `json:"password"`
+		Handle      string  `json:"handle"`
+		DisplayName *string `json:"display_name"`
+		Bio         *string `json:"bio"`
+	}
+
+	type returnVals struct {
+		Id            uuid.UUID `json:"id"`
+		CreatedAt     time.Time `json:"created_at"`
+		UpdatedAt     time.Time `json:"updated_at"`
+		Email         string    `json:"email"`
+		IsChirpyRed   bool      `json:"is_chirpy_red"`
+		EmailVerified bool      `json:"email_verified"`
+		Handle        string    `json:"handle"`
+		DisplayName   string    `json:"display_name"`
+		Bio           string    `json:"bio"`
+	}
+	token, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, 401, errCodeUnauthorized, "Missing access token")
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.signJWT)
+	if err != nil {
+		respondWithError(w, 401, errCodeUnauthorized, "Invalid access token")
+		return
+	}
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	if err := decoder.Decode(&params); err != nil {
+		msg := fmt.Sprintf("An error occurred marshaling JSON: %s", err)
+		respondWithError(w, 400, errCodeInvalidJSON, msg)
+		return
+	}
+	if params.Email == "" && params.Password == "" && params.Handle == "" && params.DisplayName == nil && params.Bio == nil {
+		respondWithError(w, 400, errCodeMissingFields, "Nothing to update")
+		return
+	}
+	if params.Email != "" && !validEmail(params.Email) {
+		respondWithError(w, 400, errCodeInvalidEmail, "A valid email address is required")
+		return
+	}
+	if params.Handle != "" {
+		if err := handle.Validate(params.Handle); err != nil {
+			respondWithError(w, 400, errCodeInvalidHandle, err.Error())
+			return
+		}
+	}
+	if params.DisplayName != nil && utf8.RuneCountInString(*params.DisplayName) > maxDisplayNameLength {
+		msg := fmt.Sprintf("display_name is longer than %d characters", maxDisplayNameLength)
+		respondWithError(w, 400, errCodeProfileTooLong, msg)
+		return
+	}
+	if params.Bio != nil && utf8.RuneCountInString(*params.Bio) > maxBioLength {
+		msg := fmt.Sprintf("bio is longer than %d characters", maxBioLength)
+		respondWithError(w, 400, errCodeProfileTooLong, msg)
+		return
+	}
+	user, err := cfg.db.GetUserByID(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, 401, errCodeUnauthorized, "Invalid access token")
+		return
+	}
+
+	userParameters := database.UpdateUserParams{
+		ID:             user.ID,
+		Email:          user.Email,
+		HashedPassword: user.HashedPassword,
+		UpdatedAt:      time.Now(),
+		Handle:         user.Handle,
+		DisplayName:    user.DisplayName,
+		Bio:            user.Bio,
+		EmailVerified:  user.EmailVerified,
+	}
+	// A new address has to be confirmed again.
+	emailChanged := params.Email != "" && params.Email != user.Email
+	if emailChanged {
+		userParameters.Email = params.Email
+		userParameters.EmailVerified = false
+	}
+	if params.Handle != "" {
+		userParameters.Handle = sql.NullString{String: params.Handle, Valid: true}
+	}
+	if params.DisplayName != nil {
+		userParameters.DisplayName = *params.DisplayName
+	}
+	if params.Bio != nil {
+		userParameters.Bio = *params.Bio
+	}
+	if params.Password != "" {
+		safePassword, err := auth.HashPassword(params.Password)
+		if err != nil {
+			msg := fmt.Sprintf("An error occurred generating a password: %s", err)
+			respondWithError(w, 500, errCodeInternal, msg)
+			return
+		}
+		userParameters.HashedPassword = safePassword
+	}
+	tx, err := cfg.sqlDB.BeginTx(req.Context(), nil)
+	if err != nil {
+		msg := fmt.Sprintf("An error occurred starting a transaction: %s", err)
+		respondWithError(w, 500, errCodeInternal, msg)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	user, err = qtx.UpdateUser(req.Context(), userParameters)
+	if code, msg, conflict := userConflict(err); conflict {
+		respondWithError(w, 409, code, msg)
+		return
+	}
+	if err != nil {
+		msg := fmt.Sprintf("An error occurred updating the user: %s", err)
+		respondWithError(w, 500, errCodeInternal, msg)
+		return
+	}
+	// The new password and the signed-out sessions land together, so a
+	// failure cannot leave old refresh tokens valid for the new credentials.
+	if params.Password != "" {
+		now := time.Now()
+		revokeParams := database.RevokeUserTokensParams{
+			UpdatedAt: now,
+			RevokedAt: sql.NullTime{Time: now, Valid: true},
+			UserID:    user.ID,
+		}
+		err = qtx.RevokeUserTokens(req.Context(), revokeParams)
+		if err != nil {
+			msg := fmt.Sprintf("An error occurred revoking refresh tokens: %s", err)
+			respondWithError(w, 500, errCodeInternal, msg)
+			return
+		}
+		// A reset link mailed before the change must not undo it.
+		err = qtx.DeletePasswordResets(req.Context(), user.ID)
+		if err != nil {
+			msg := fmt.Sprintf("An error occurred clearing password resets: %s", err)
+			respondWithError(w, 500, errCodeInternal, msg)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		msg := fmt.Sprintf("An error occurred updating the user: %s", err)
+		respondWithError(w, 500, errCodeInternal, msg)
+		return
+	}
+	if emailChanged {
+		if err := cfg.sendVerification(req.Context(), user); err != nil {
+			log.Printf("An error occurred sending the verification email: %s", err)
+		}
+	}
+	respBody := returnVals{
+		Id:            user.ID,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+		Email:         user.Email,
+		IsChirpyRed:   user.IsChirpyRed,
+		EmailVerified: user.EmailVerified,
+		Handle:        user.Handle.String,
+		DisplayName:   user.DisplayName,
+		Bio:           user.Bio,
+	}
+	out, err := json.Marshal(respBody)
+	if err != nil {
+		msg := fmt.Sprintf("An error occurred marshaling the JSON data: %s\n", err)
+		respondWithError(w, 500, errCodeInternal, msg)
+		return
+	}
+	respondWithJSON(w, 200, out)
+}
+
 func (cfg *apiConfig) loginHandler(w http.ResponseWriter, req *http.Request) {
 	type parameters struct {
 		Password         string `json:"password"`
 		Email            string `json:"email"`
 		ExpiresInSeconds int    `json:"expires_in_seconds"`
 	}
-	type outerface struct {
-		ID           uuid.UUID `json:"id"`
-		CreatedAt    time.Time `json:"created_at"`
-		UpdatedAt    time.Time `json:"updated_at"`
-		Email        string    `json:"email"`
-		Token        string    `json:"token"`
-		RefreshToken string    `json:"refresh_token"`
-	}
 	decoder := json.NewDecoder(req.Body)
 	params := parameters{}
 	if err := decoder.Decode(&params); err != nil {
 		msg := fmt.Sprintf("An error occurred marshaling JSON: %s", err)
-		respondWithError(w, 400, msg)
+		respondWithError(w, 400, errCodeInvalidJSON, msg)
+		return
+	}
+	ip := clientIP(req)
+	now := time.Now()
+	if wait, _ := cfg.ipLogins.Check(ip, now); wait > 0 {
+		respondLoginWait(w, wait, false)
 		return
 	}
 	user, err := cfg.db.GetUserByEmail(req.Context(), params.Email)
-	if err != nil {
-		respondWithError(w, 401, "Incorrect email or password")
+	found := err == nil
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("An error occurred retrieving the user: %s", err)
+		respondWithError(w, 500, errCodeInternal, "An error occurred retrieving the user")
+		return
+	}
+	if !cfg.checkLogin(w, params.Email, user, found, now) {
 		return
 	}
 
-	err = auth.CheckPasswordHash(params.Password, user.HashedPassword)
-	if err != nil {
-		respondWithError(w, 401, "Incorrect email or password")
+	hash := user.HashedPassword
+	if !found {
+		hash = dummyPasswordHash()
+	}
+	err = auth.CheckPasswordHash(params.Password, hash)
+	if err != nil || !found {
+		if err := cfg.recordLoginFailure(req.Context(), ip, params.Email, user, found, now); err != nil {
+			log.Printf("An error occurred recording a failed login: %s", err)
+		}
+		respondWithError(w, 401, errCodeInvalidCredentials, "Incorrect email or password")
 		return
 	}
+	// A second factor is still owed, so the failure count stands until
+	// the code is checked as well.
+	if user.TotpEnabled {
+		cfg.respondMFARequired(w, user)
+		return
+	}
+	cfg.completeLogin(w, req, user, params.ExpiresInSeconds)
+}
+
+type loginResponse struct {
+	ID            uuid.UUID `json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	Email         string    `json:"email"`
+	IsChirpyRed   bool      `json:"is_chirpy_red"`
+	EmailVerified bool      `json:"email_verified"`
+	MFAEnabled    bool      `json:"mfa_enabled"`
+	Handle        string    `json:"handle"`
+	Token         string    `json:"token"`
+	RefreshToken  string    `json:"refresh_token"`
+}
+
+// completeLogin clears the account's failed logins and responds with a
+// new access token and refresh token family.
+func (cfg *apiConfig) completeLogin(w http.ResponseWriter, req *http.Request, user database.User, expiresInSeconds int) {
+	if user.FailedLogins > 0 || user.LockedUntil.Valid {
+		if err := cfg.db.ResetLoginFailures(req.Context(), user.ID); err != nil {
+			log.Printf("An error occurred clearing failed logins: %s", err)
+		}
+	}
 	expirationTime := 60 * 60
-	if params.ExpiresInSeconds > 0 {
-		if params.ExpiresInSeconds < expirationTime {
-			expirationTime = params.ExpiresInSeconds
+	if expiresInSeconds > 0 {
+		if expiresInSeconds < expirationTime {
+			expirationTime = expiresInSeconds
 		}
 	}
 	durationString := fmt.Sprintf("%vs", expirationTime)
 	d, err := time.ParseDuration(durationString)
 	if err != nil {
-		respondWithError(w, 500, "Internal error parsing time")
+		respondWithError(w, 500, errCodeInternal, "Internal error parsing time")
+		return
 	}
 	token, err := auth.MakeJWT(user.ID, cfg.signJWT, d)
 	if err != nil {
-		respondWithError(w, 500, "Could not create JWT")
+		respondWithError(w, 500, errCodeInternal, "Could not create JWT")
+		return
+	}
+	refreshToken, err := cfg.issueRefreshToken(req.Context(), cfg.db, user.ID, uuid.New())
+	if err != nil {
+		msg := fmt.Sprintf("An error occurred saving the refresh token: %s", err)
+		respondWithError(w, 500, errCodeInternal, msg)
+		return
 	}
+	data := loginResponse{
+		ID:            user.ID,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+		Email:         user.Email,
+		IsChirpyRed:   user.IsChirpyRed,
+		EmailVerified: user.EmailVerified,
+		MFAEnabled:    user.TotpEnabled,
+		Handle:        user.Handle.String,
+		Token:         token,
+		RefreshToken:  refreshToken,
+	}
+	out, err := json.Marshal(data)
+	if err != nil {
+		respondWithError(w, 500, errCodeInternal, "A marshaling error occurred")
+		return
+	}
+	respondWithJSON(w, 200, out)
+}
+
+// issueRefreshToken stores a new refresh token for the user in the given
+// rotation family and returns its value.
+func (cfg *apiConfig) issueRefreshToken(ctx context.Context, q *database.Queries, userID, family uuid.UUID) (string, error) {
 	refreshToken, err := auth.MakeRefreshToken()
 	if err != nil {
-		respondWithError(w, 500, "Error generating refresh token")
+		return "", err
+	}
+	tokenParams := database.CreateRefreshTokenParams{
+		Token:     refreshToken,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(refreshTokenLifetime),
+		Family:    family,
+	}
+	_, err = q.CreateRefreshToken(ctx, tokenParams)
+	if err != nil {
+		return "", err
+	}
+	return refreshToken, nil
+}
+
+// revokeTokenFamily revokes every live token in a rotation chain. It is
+// called when a rotated refresh token is presented again, which means the
+// chain has leaked.
+func (cfg *apiConfig) revokeTokenFamily(ctx context.Context, family uuid.UUID) {
+	familyParams := database.RevokeTokenFamilyParams{
+		UpdatedAt: time.Now(),
+		RevokedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		Family:    family,
+	}
+	err := cfg.db.RevokeTokenFamily(ctx, familyParams)
+	if err != nil {
+		log.Printf("An error occurred revoking refresh token family %s: %s", family, err)
+	}
+}
+
+func (cfg *apiConfig) refreshHandler(w http.ResponseWriter, req *http.Request) {
+	type outerface struct {
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	refreshToken, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, 401, errCodeInvalidRefreshToken, "Missing refresh token")
+		return
+	}
+	stored, err := cfg.db.GetRefreshToken(req.Context(), refreshToken)
+	if err != nil {
+		respondWithError(w, 401, errCodeInvalidRefreshToken, "Invalid refresh token")
+		return
+	}
+	err = auth.CheckRefreshToken(stored.ExpiresAt, stored.RevokedAt.Valid, stored.ReplacedBy.Valid)
+	if errors.Is(err, auth.ErrRefreshTokenReused) {
+		cfg.revokeTokenFamily(req.Context(), stored.Family)
+		respondWithError(w, 401, errCodeInvalidRefreshToken, "Invalid refresh token")
+		return
+	}
+	if err != nil {
+		respondWithError(w, 401, errCodeInvalidRefreshToken, "Invalid refresh token")
+		return
+	}
+
+	// The successor is stored and the old token marked as replaced by it
+	// together, so a failure cannot leave the family half rotated.
+	tx, err := cfg.sqlDB.BeginTx(req.Context(), nil)
+	if err != nil {
+		msg := fmt.Sprintf("An error occurred rotating the refresh token: %s", err)
+		respondWithError(w, 500, errCodeInternal, msg)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+	newRefreshToken, err := cfg.issueRefreshToken(req.Context(), qtx, stored.UserID, stored.Family)
+	if err != nil {
+		msg := fmt.Sprintf("An error occurred saving the refresh token: %s", err)
+		respondWithError(w, 500, errCodeInternal, msg)
+		return
+	}
+	rotateParams := database.RotateRefreshTokenParams{
+		UpdatedAt:  time.Now(),
+		RevokedAt:  sql.NullTime{Time: time.Now(), Valid: true},
+		ReplacedBy: sql.NullString{String: newRefreshToken, Valid: true},
+		Token:      refreshToken,
+	}
+	rows, err := qtx.RotateRefreshToken(req.Context(), rotateParams)
+	if err != nil {
+		msg := fmt.Sprintf("An error occurred rotating the refresh token: %s", err)
+		respondWithError(w, 500, errCodeInternal, msg)
+		return
+	}
+	if rows == 0 {
+		// Another request rotated or revoked this token first.  Only a
+		// rotation makes this a reuse.
+		tx.Rollback()
+		current, err := cfg.db.GetRefreshToken(req.Context(), refreshToken)
+		if err == nil && current.ReplacedBy.Valid {
+			cfg.revokeTokenFamily(req.Context(), stored.Family)
+		}
+		respondWithError(w, 401, errCodeInvalidRefreshToken, "Invalid refresh token")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		msg := fmt.Sprintf("An error occurred rotating the refresh token: %s", err)
+		respondWithError(w, 500, errCodeInternal, msg)
+		return
+	}
+	token, err := auth.MakeJWT(stored.UserID, cfg.signJWT, time.Hour)
+	if err != nil {
+		respondWithError(w, 500, errCodeInternal, "Could not create JWT")
+		return
 	}
 	data := outerface{
-		ID:           user.ID,
-		CreatedAt:    user.CreatedAt,
-		UpdatedAt:    user.UpdatedAt,
-		Email:        user.Email,
 		Token:        token,
-		RefreshToken: refreshToken,
+		RefreshToken: newRefreshToken,
+	}
+	out, err := json.Marshal(data)
+	if err != nil {
+		respondWithError(w, 500, errCodeInternal, "A marshaling error occurred")
+		return
+	}
+	respondWithJSON(w, 200, out)
+}
+
+func (cfg *apiConfig) revokeHandler(w http.ResponseWriter, req *http.Request) {
+	refreshToken, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, 401, errCodeInvalidRefreshToken, "Missing refresh token")
+		return
+	}
+	revokeParams := database.RevokeTokenParams{
+		UpdatedAt: time.Now(),
+		RevokedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		Token:     refreshToken,
+	}
+	err = cfg.db.RevokeToken(req.Context(), revokeParams)
+	if err != nil {
+		msg := fmt.Sprintf("An error occurred revoking the refresh token: %s", err)
+		respondWithError(w, 500, errCodeInternal, msg)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) polkaWebhookHandler(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Event string `json:"event"`
+		Data  struct {
+			UserID uuid.UUID `json:"user_id"`
+		} `json:"data"`
+	}
+	key, err := auth.GetAPIKey(req.Header)
+	if err != nil || cfg.polkaKey == "" || subtle.ConstantTimeCompare([]byte(key), []byte(cfg.polkaKey)) != 1 {
+		respondWithError(w, 401, errCodeInvalidAPIKey, "Invalid API key")
+		return
+	}
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	if err := decoder.Decode(&params); err != nil {
+		msg := fmt.Sprintf("An error occurred marshaling JSON: %s", err)
+		respondWithError(w, 400, errCodeInvalidJSON, msg)
+		return
+	}
+	if params.Event != "user.upgraded" {
+		w.WriteHeader(204)
+		return
+	}
+	upgradeParams := database.UpgradeToChirpyRedParams{
+		ID:        params.Data.UserID,
+		UpdatedAt: time.Now(),
+	}
+	rows, err := cfg.db.UpgradeToChirpyRed(req.Context(), upgradeParams)
+	if err != nil {
+		msg := fmt.Sprintf("An error occurred upgrading the user: %s", err)
+		respondWithError(w, 500, errCodeInternal, msg)
+		return
+	}
+	if rows == 0 {
+		respondWithError(w, 404, errCodeUserNotFound, "User not found")
+		return
+	}
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) getProfileHandler(w http.ResponseWriter, req *http.Request) {
+	type outerface struct {
+		ID             uuid.UUID   `json:"id"`
+		Handle         string      `json:"handle"`
+		DisplayName    string      `json:"display_name"`
+		Bio            string      `json:"bio"`
+		Avatar         *avatarURLs `json:"avatar"`
+		JoinedAt       time.Time   `json:"joined_at"`
+		ChirpCount     int64       `json:"chirp_count"`
+		FollowerCount  int64       `json:"follower_count"`
+		FollowingCount int64       `json:"following_count"`
+	}
+	profile, err := cfg.db.GetUserProfile(req.Context(), req.PathValue("handle"))
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 404, errCodeUserNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		msg := fmt.Sprintf("An error occurred retrieving the profile: %s", err)
+		respondWithError(w, 500, errCodeInternal, msg)
+		return
+	}
+	data := outerface{
+		ID:             profile.ID,
+		Handle:         profile.Handle.String,
+		DisplayName:    profile.DisplayName,
+		Bio:            profile.Bio,
+		Avatar:         newAvatarURLs(profile.AvatarUrl48, profile.AvatarUrl128, profile.AvatarUrl400),
+		JoinedAt:       profile.CreatedAt,
+		ChirpCount:     profile.ChirpCount,
+		FollowerCount:  profile.FollowerCount,
+		FollowingCount: profile.FollowingCount,
 	}
 	out, err := json.Marshal(data)
 	if err != nil {
-		respondWithError(w, 500, "A marshaling error occurred")
+		respondWithError(w, 500, errCodeInternal, "A marshaling error occurred")
 		return
 	}
 	respondWithJSON(w, 200, out)
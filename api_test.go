@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestRefreshTokenReuse(t *testing.T) {
+	db, queries := openTestDB(t)
+	user := createTestUser(t, db, queries, "unused")
+	cfg := &apiConfig{db: queries, sqlDB: db, signJWT: testSecret}
+	first, err := cfg.issueRefreshToken(context.Background(), queries, user.ID, uuid.New())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	refresh := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/refresh", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		cfg.refreshHandler(rec, req)
+		return rec
+	}
+
+	rec := refresh(first)
+	if rec.Code != 200 {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var rotated struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &rotated); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	stored, err := queries.GetRefreshToken(context.Background(), first)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if stored.ReplacedBy.String != rotated.RefreshToken {
+		t.Errorf("Expected the old token to be replaced by the new one, got %q", stored.ReplacedBy.String)
+	}
+
+	// Presenting the rotated token again means it leaked, so the token
+	// the client was given in its place stops working too.
+	if rec := refresh(first); rec.Code != 401 {
+		t.Fatalf("Expected status 401 for the reused token, got %d", rec.Code)
+	}
+	if rec := refresh(rotated.RefreshToken); rec.Code != 401 {
+		t.Fatalf("Expected status 401 for the rest of the family, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/interyx/chirpy/internal/database"
+	"github.com/interyx/chirpy/internal/media"
+)
+
+// avatarSizes are the edge lengths, in pixels, of the square thumbnails
+// made from every avatar upload.
+var avatarSizes = [3]int{48, 128, 400}
+
+// mediaURLPrefix is where mediaHandler serves blobs.  Avatars are recorded
+// as URLs under it rather than signed URLs so they never expire, whichever
+// backend holds the files.
+const mediaURLPrefix = "/media/"
+
+// avatarURLs is the public shape of a user's avatar thumbnails.
+type avatarURLs struct {
+	Small  string `json:"48"`
+	Medium string `json:"128"`
+	Large  string `json:"400"`
+}
+
+// newAvatarURLs returns nil for a user who has not uploaded an avatar.
+func newAvatarURLs(small, medium, large string) *avatarURLs {
+	if small == "" {
+		return nil
+	}
+	return &avatarURLs{Small: small, Medium: medium, Large: large}
+}
+
+func (cfg *apiConfig) uploadAvatarHandler(w http.ResponseWriter, req *http.Request) {
+	type outerface struct {
+		Avatar *avatarURLs `json:"avatar"`
+	}
+	userID, ok := cfg.authenticate(w, req)
+	if !ok {
+		return
+	}
+	req.Body = http.MaxBytesReader(w, req.Body, maxImageSize+1<<20)
+	file, header, err := req.FormFile("avatar")
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		msg := fmt.Sprintf("Avatars are limited to %d MB", maxImageSize>>20)
+		respondWithError(w, 413, errCodeImageTooLarge, msg)
+		return
+	}
+	if err != nil {
+		msg := fmt.Sprintf("An avatar file is required: %s", err)
+		respondWithError(w, 400, errCodeInvalidForm, msg)
+		return
+	}
+	defer file.Close()
+	if header.Size > maxImageSize {
+		msg := fmt.Sprintf("Avatars are limited to %d MB", maxImageSize>>20)
+		respondWithError(w, 413, errCodeImageTooLarge, msg)
+		return
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		log.Printf("An error occurred reading an avatar: %s", err)
+		respondWithError(w, 500, errCodeInternal, "An error occurred reading the upload")
+		return
+	}
+	contentType, err := media.Sniff(data)
+	if err != nil || (contentType != "image/jpeg" && contentType != "image/png") {
+		respondWithError(w, 415, errCodeUnsupportedMedia, "Avatars must be JPEG or PNG images")
+		return
+	}
+	img, err := media.Decode(data)
+	if err != nil {
+		respondWithError(w, 400, errCodeInvalidImage, err.Error())
+		return
+	}
+
+	user, err := cfg.db.GetUserByID(req.Context(), userID)
+	if err != nil {
+		log.Printf("An error occurred retrieving the user: %s", err)
+		respondWithError(w, 500, errCodeInternal, "An error occurred retrieving the user")
+		return
+	}
+
+	// Every upload gets fresh keys so cached copies of the old avatar are
+	// never served in its place.
+	uploadID := uuid.New()
+	var urls [len(avatarSizes)]string
+	var stored []string
+	for i, size := range avatarSizes {
+		var buf bytes.Buffer
+		if err := encodeAvatar(&buf, media.Thumbnail(img, size), contentType); err != nil {
+			log.Printf("An error occurred encoding an avatar: %s", err)
+			respondWithError(w, 500, errCodeInternal, "An error occurred processing the avatar")
+			cfg.deleteBlobs(req.Context(), stored)
+			return
+		}
+		key := fmt.Sprintf("avatars/%s/%s-%d%s", userID, uploadID, size, media.Extension(contentType))
+		if err := cfg.media.Put(req.Context(), key, &buf, contentType); err != nil {
+			log.Printf("An error occurred storing an avatar: %s", err)
+			respondWithError(w, 500, errCodeInternal, "An error occurred storing the avatar")
+			cfg.deleteBlobs(req.Context(), stored)
+			return
+		}
+		stored = append(stored, key)
+		urls[i] = mediaURLPrefix + key
+	}
+
+	updated, err := cfg.db.UpdateUserAvatar(req.Context(), database.UpdateUserAvatarParams{
+		ID:           userID,
+		AvatarUrl48:  urls[0],
+		AvatarUrl128: urls[1],
+		AvatarUrl400: urls[2],
+		UpdatedAt:    time.Now(),
+	})
+	if err != nil {
+		log.Printf("An error occurred recording the avatar: %s", err)
+		respondWithError(w, 500, errCodeInternal, "An error occurred storing the avatar")
+		cfg.deleteBlobs(req.Context(), stored)
+		return
+	}
+	cfg.deleteBlobs(req.Context(), avatarKeys(user))
+
+	out, err := json.Marshal(outerface{
+		Avatar: newAvatarURLs(updated.AvatarUrl48, updated.AvatarUrl128, updated.AvatarUrl400),
+	})
+	if err != nil {
+		respondWithError(w, 500, errCodeInternal, "A marshaling error occurred")
+		return
+	}
+	respondWithJSON(w, 200, out)
+}
+
+// encodeAvatar writes PNG uploads back out as PNG, to keep transparency,
+// and everything else as JPEG.
+func encodeAvatar(w io.Writer, img image.Image, contentType string) error {
+	if contentType == "image/png" {
+		return png.Encode(w, img)
+	}
+	return jpeg.Encode(w, img, &jpeg.Options{Quality: 85})
+}
+
+// avatarKeys recovers the storage keys of a user's current avatar.
+func avatarKeys(user database.User) []string {
+	var keys []string
+	for _, url := range []string{user.AvatarUrl48, user.AvatarUrl128, user.AvatarUrl400} {
+		if key, found := strings.CutPrefix(url, mediaURLPrefix); found {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
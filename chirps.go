@@ -1,132 +1,568 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
-	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/interyx/chirpy/internal/auth"
 	"github.com/interyx/chirpy/internal/database"
+	"github.com/interyx/chirpy/internal/profanity"
+	"github.com/interyx/chirpy/internal/tags"
 )
 
 func (cfg *apiConfig) getChirpsHandler(w http.ResponseWriter, req *http.Request) {
-	chirps, err := cfg.db.GetChirps(req.Context())
+	sortOrder := req.URL.Query().Get("sort")
+	if sortOrder != "" && sortOrder != "asc" && sortOrder != "desc" {
+		respondWithError(w, 400, errCodeInvalidSort, "sort must be asc or desc")
+		return
+	}
+	descending := sortOrder == "desc"
+
+	viewer, hasViewer, ok := cfg.optionalViewer(w, req)
+	if !ok {
+		return
+	}
+	limit, cursor, err := pageParams(req, descending)
+	if err != nil {
+		respondWithError(w, 400, errCodeInvalidPagination, err.Error())
+		return
+	}
+
+	// Ask for one extra row to learn whether another page follows.
+	var chirps []database.Chirp
+	if authorParam := req.URL.Query().Get("author_id"); authorParam != "" {
+		authorID, parseErr := uuid.Parse(authorParam)
+		if parseErr != nil {
+			respondWithError(w, 400, errCodeInvalidID, "author_id is not a valid UUID")
+			return
+		}
+		if descending {
+			chirps, err = cfg.db.GetChirpsByAuthorDesc(req.Context(), database.GetChirpsByAuthorDescParams{
+				UserID:          authorID,
+				CursorCreatedAt: cursor.CreatedAt,
+				CursorID:        cursor.ID,
+				PageSize:        limit + 1,
+			})
+		} else {
+			chirps, err = cfg.db.GetChirpsByAuthor(req.Context(), database.GetChirpsByAuthorParams{
+				UserID:          authorID,
+				CursorCreatedAt: cursor.CreatedAt,
+				CursorID:        cursor.ID,
+				PageSize:        limit + 1,
+			})
+		}
+	} else if descending {
+		chirps, err = cfg.db.GetChirpsDesc(req.Context(), database.GetChirpsDescParams{
+			CursorCreatedAt: cursor.CreatedAt,
+			CursorID:        cursor.ID,
+			PageSize:        limit + 1,
+		})
+	} else {
+		chirps, err = cfg.db.GetChirps(req.Context(), database.GetChirpsParams{
+			CursorCreatedAt: cursor.CreatedAt,
+			CursorID:        cursor.ID,
+			PageSize:        limit + 1,
+		})
+	}
 	if err != nil {
 		log.Printf("An error occurred getting values from the database: %s", err)
-		w.WriteHeader(500)
+		respondWithError(w, 500, errCodeInternal, "An error occurred retrieving chirps")
 		return
 	}
-	out, err := json.Marshal(chirps)
+	cfg.writeChirpPage(w, req, chirps, limit, viewer, hasViewer)
+}
+
+func (cfg *apiConfig) getChirpHandler(w http.ResponseWriter, req *http.Request) {
+	viewer, hasViewer, ok := cfg.optionalViewer(w, req)
+	if !ok {
+		return
+	}
+	id, err := uuid.Parse(req.PathValue("id"))
+	if err != nil {
+		respondWithError(w, 400, errCodeInvalidID, "Chirp ID is not a valid UUID")
+		return
+	}
+	chirp, err := cfg.db.GetChirp(req.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 404, errCodeChirpNotFound, "Chirp not found")
+		return
+	}
+	if err != nil {
+		log.Printf("An error occurred retrieving the record: %s", err)
+		respondWithError(w, 500, errCodeInternal, "An error occurred retrieving the chirp")
+		return
+	}
+	resp, err := cfg.chirpResponses(req.Context(), []database.Chirp{chirp}, viewer, hasViewer)
+	if err != nil {
+		log.Printf("An error occurred getting like counts: %s", err)
+		respondWithError(w, 500, errCodeInternal, "An error occurred retrieving the chirp")
+		return
+	}
+	out, err := json.Marshal(resp[0])
 	if err != nil {
 		log.Printf("An error occurred marshalling JSON: %s", err)
-		w.WriteHeader(500)
+		respondWithError(w, 500, errCodeInternal, "A marshaling error occurred")
 		return
 	}
-	w.WriteHeader(200)
-	w.Write(out)
+	respondWithJSON(w, 200, out)
 }
 
-func (cfg *apiConfig) getChirpHandler(w http.ResponseWriter, req *http.Request) {
+func (cfg *apiConfig) getThreadHandler(w http.ResponseWriter, req *http.Request) {
+	type outerface struct {
+		Ancestors []chirpResponse `json:"ancestors"`
+		Chirp     chirpResponse   `json:"chirp"`
+		Replies   []chirpResponse `json:"replies"`
+	}
+	viewer, hasViewer, ok := cfg.optionalViewer(w, req)
+	if !ok {
+		return
+	}
 	id, err := uuid.Parse(req.PathValue("id"))
 	if err != nil {
-		log.Printf("UUID could not be parsed\n%s", err)
-		w.WriteHeader(400)
+		respondWithError(w, 400, errCodeInvalidID, "Chirp ID is not a valid UUID")
+		return
+	}
+	limit, cursor, err := pageParams(req, false)
+	if err != nil {
+		respondWithError(w, 400, errCodeInvalidPagination, err.Error())
 		return
 	}
 	chirp, err := cfg.db.GetChirp(req.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 404, errCodeChirpNotFound, "Chirp not found")
+		return
+	}
 	if err != nil {
 		log.Printf("An error occurred retrieving the record: %s", err)
-		w.WriteHeader(400)
+		respondWithError(w, 500, errCodeInternal, "An error occurred retrieving the chirp")
+		return
+	}
+	ancestors, err := cfg.db.GetChirpAncestors(req.Context(), id)
+	if err != nil {
+		log.Printf("An error occurred retrieving ancestors of %s: %s", id, err)
+		respondWithError(w, 500, errCodeInternal, "An error occurred retrieving the thread")
+		return
+	}
+	// Ask for one extra row to learn whether another page follows.
+	replies, err := cfg.db.GetReplies(req.Context(), database.GetRepliesParams{
+		ParentID:        uuid.NullUUID{UUID: id, Valid: true},
+		CursorCreatedAt: cursor.CreatedAt,
+		CursorID:        cursor.ID,
+		PageSize:        limit + 1,
+	})
+	if err != nil {
+		log.Printf("An error occurred retrieving replies to %s: %s", id, err)
+		respondWithError(w, 500, errCodeInternal, "An error occurred retrieving the thread")
 		return
 	}
-	out, err := json.Marshal(chirp)
+	replies = trimChirpPage(w, req, replies, limit)
+
+	// Decorate the whole thread in one pass so counts cost one query each.
+	all := make([]database.Chirp, 0, len(ancestors)+1+len(replies))
+	all = append(all, ancestors...)
+	all = append(all, chirp)
+	all = append(all, replies...)
+	resp, err := cfg.chirpResponses(req.Context(), all, viewer, hasViewer)
+	if err != nil {
+		log.Printf("An error occurred getting chirp counts: %s", err)
+		respondWithError(w, 500, errCodeInternal, "An error occurred retrieving the thread")
+		return
+	}
+	data := outerface{
+		Ancestors: resp[:len(ancestors)],
+		Chirp:     resp[len(ancestors)],
+		Replies:   resp[len(ancestors)+1:],
+	}
+	out, err := json.Marshal(data)
 	if err != nil {
 		log.Printf("An error occurred marshalling JSON: %s", err)
-		w.WriteHeader(500)
+		respondWithError(w, 500, errCodeInternal, "A marshaling error occurred")
 		return
 	}
-	w.WriteHeader(200)
-	w.Write(out)
+	respondWithJSON(w, 200, out)
 }
 
 func (cfg *apiConfig) createChirpHandler(w http.ResponseWriter, req *http.Request) {
 	type parameters struct {
-		Body string `json:"body"`
+		Body      string        `json:"body"`
+		InReplyTo uuid.NullUUID `json:"in_reply_to"`
 	}
-	decoder := json.NewDecoder(req.Body)
-	params := parameters{}
-	if err := decoder.Decode(&params); err != nil {
-		log.Printf("Error decoding request string: %s", err)
-		w.WriteHeader(400)
+	// Authenticate before the body is read, so only signed-in users can
+	// make the server buffer and decode uploads.
+	userID, ok := cfg.authenticate(w, req)
+	if !ok {
 		return
 	}
-
-	var chirp string
-	if len(params.Body) <= 140 {
-		chirp = cleanString(params.Body)
+	params := parameters{}
+	var uploads []chirpUpload
+	if isMultipart(req) {
+		var ok bool
+		params.Body, params.InReplyTo, uploads, ok = readChirpForm(w, req)
+		if !ok {
+			return
+		}
 	} else {
-		w.WriteHeader(400)
-		return
+		decoder := json.NewDecoder(req.Body)
+		if err := decoder.Decode(&params); err != nil {
+			msg := fmt.Sprintf("An error occurred marshaling JSON: %s", err)
+			respondWithError(w, 400, errCodeInvalidJSON, msg)
+			return
+		}
 	}
 
-	token, err := auth.GetBearerToken(req.Header)
-	if err != nil {
-		w.WriteHeader(401)
+	chirp, matches, ok := cfg.prepareChirpBody(w, params.Body)
+	if !ok {
 		return
 	}
 
-	userID, err := auth.ValidateJWT(token, cfg.signJWT)
-
-	if err != nil {
-		w.WriteHeader(401)
+	if !cfg.requireVerified(w, req, userID) {
 		return
 	}
 
+	if params.InReplyTo.Valid {
+		_, err := cfg.db.GetChirp(req.Context(), params.InReplyTo.UUID)
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, 400, errCodeParentNotFound, "The chirp being replied to does not exist")
+			return
+		}
+		if err != nil {
+			log.Printf("An error occurred retrieving the parent chirp: %s", err)
+			respondWithError(w, 500, errCodeInternal, "An error occurred retrieving the parent chirp")
+			return
+		}
+	}
+
 	chirpParams := database.CreateChirpParams{
 		ID:        uuid.New(),
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 		Body:      chirp,
 		UserID:    userID,
+		InReplyTo: params.InReplyTo,
+	}
+	mediaRows, err := cfg.putChirpMedia(req.Context(), chirpParams.ID, uploads)
+	if err != nil {
+		log.Printf("An error occurred storing chirp images: %s\n", err)
+		respondWithError(w, 500, errCodeInternal, "An error occurred storing the images")
+		return
 	}
-	newChirp, err := cfg.db.CreateChirp(req.Context(), chirpParams)
+	newChirp, err := cfg.storeChirp(req.Context(), chirpParams, matches, mediaRows)
 	if err != nil {
+		cfg.deleteBlobs(req.Context(), mediaKeys(mediaRows))
 		log.Printf("An error occurred while creating the chirp: %s\n", err)
-		w.WriteHeader(400)
+		respondWithError(w, 500, errCodeInternal, "An error occurred creating the chirp")
 		return
 	}
-	out, err := json.Marshal(newChirp)
+	resp, err := cfg.chirpResponses(req.Context(), []database.Chirp{newChirp}, userID, true)
+	if err != nil {
+		log.Printf("An error occurred loading the new chirp: %s", err)
+		respondWithError(w, 500, errCodeInternal, "An error occurred loading the new chirp")
+		return
+	}
+	out, err := json.Marshal(resp[0])
 	if err != nil {
 		log.Printf("An error occurred marshaling JSON data: %s", err)
+		respondWithError(w, 500, errCodeInternal, "A marshaling error occurred")
 		return
 	}
-	w.WriteHeader(201)
-	w.Write(out)
+	respondWithJSON(w, 201, out)
 }
 
-func cleanString(str string) string {
-	banned_words := []string{
-		"kerfuffle",
-		"sharbert",
-		"fornax",
+// prepareChirpBody applies the length limit and profanity mode shared by
+// every endpoint that stores chirp text.  On failure it writes a 400 and
+// returns false.
+func (cfg *apiConfig) prepareChirpBody(w http.ResponseWriter, body string) (string, []string, bool) {
+	if len(body) > 140 {
+		respondWithError(w, 400, errCodeChirpTooLong, "Chirp is longer than 140 characters")
+		return "", nil, false
+	}
+	chirp, matches := cfg.moderateChirp(body)
+	if cfg.profanityMode == profanity.ModeReject && len(matches) > 0 {
+		respondWithError(w, 400, errCodeProfanity, "Chirp contains banned words")
+		return "", nil, false
 	}
-	results := make([]string, len(str))
-	wordsList := strings.Split(str, " ")
-	for _, word := range wordsList {
-		added := false
-		for _, badWord := range banned_words {
-			if strings.ToLower(word) == badWord {
-				results = append(results, "****")
-				added = true
-				break
-			}
+	return chirp, matches, true
+}
+
+// storeChirp inserts a chirp together with its media, hashtag and mention
+// rows, and its review flag when profanity was found, in a single
+// transaction.  Mentions of handles that do not exist are skipped.
+func (cfg *apiConfig) storeChirp(ctx context.Context, chirpParams database.CreateChirpParams, matches []string, media []database.AddChirpMediaParams) (database.Chirp, error) {
+	tx, err := cfg.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	chirp, err := qtx.CreateChirp(ctx, chirpParams)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	for _, row := range media {
+		err = qtx.AddChirpMedia(ctx, row)
+		if err != nil {
+			return database.Chirp{}, err
+		}
+	}
+	for _, tag := range tags.Hashtags(chirp.Body) {
+		err = qtx.AddChirpHashtag(ctx, database.AddChirpHashtagParams{
+			ChirpID: chirp.ID,
+			Tag:     tag,
+		})
+		if err != nil {
+			return database.Chirp{}, err
+		}
+	}
+	for _, handle := range tags.Mentions(chirp.Body) {
+		_, err = qtx.AddChirpMention(ctx, database.AddChirpMentionParams{
+			ChirpID: chirp.ID,
+			Handle:  handle,
+		})
+		if err != nil {
+			return database.Chirp{}, err
+		}
+	}
+	err = cfg.flagChirp(ctx, qtx, chirp.ID, matches)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	return chirp, tx.Commit()
+}
+
+func (cfg *apiConfig) deleteChirpHandler(w http.ResponseWriter, req *http.Request) {
+	token, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, 401, errCodeUnauthorized, "Missing access token")
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.signJWT)
+	if err != nil {
+		respondWithError(w, 401, errCodeUnauthorized, "Invalid access token")
+		return
+	}
+	id, err := uuid.Parse(req.PathValue("id"))
+	if err != nil {
+		respondWithError(w, 400, errCodeInvalidID, "Chirp ID is not a valid UUID")
+		return
+	}
+	chirp, err := cfg.db.GetChirp(req.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 404, errCodeChirpNotFound, "Chirp not found")
+		return
+	}
+	if err != nil {
+		log.Printf("An error occurred retrieving the record: %s", err)
+		respondWithError(w, 500, errCodeInternal, "An error occurred retrieving the chirp")
+		return
+	}
+	if chirp.UserID != userID {
+		respondWithError(w, 403, errCodeForbidden, "Only the author can delete a chirp")
+		return
+	}
+	attached, err := cfg.db.GetChirpMedia(req.Context(), []uuid.UUID{id})
+	if err != nil {
+		log.Printf("An error occurred retrieving the chirp's images: %s", err)
+		respondWithError(w, 500, errCodeInternal, "An error occurred deleting the chirp")
+		return
+	}
+	err = cfg.db.DeleteChirp(req.Context(), id)
+	if err != nil {
+		log.Printf("An error occurred deleting the chirp: %s", err)
+		respondWithError(w, 500, errCodeInternal, "An error occurred deleting the chirp")
+		return
+	}
+	keys := make([]string, len(attached))
+	for i, row := range attached {
+		keys[i] = row.StorageKey
+	}
+	cfg.deleteBlobs(req.Context(), keys)
+	w.WriteHeader(204)
+}
+
+// chirpResponse is the public shape of a chirp.  It embeds database.Chirp
+// so the original fields keep their names and only new ones are added.
+type chirpResponse struct {
+	database.Chirp
+	Author     *chirpAuthor `json:"author,omitempty"`
+	LikeCount  int64        `json:"like_count"`
+	ReplyCount int64        `json:"reply_count"`
+	LikedByMe  *bool        `json:"liked_by_me,omitempty"`
+	// Media lists attached images in upload order.
+	Media []mediaResponse `json:"media"`
+	// Original is only set on reposts.
+	Original *repostOriginal `json:"original,omitempty"`
+}
+
+// chirpAuthor is the public summary of whoever wrote a chirp.
+type chirpAuthor struct {
+	ID          uuid.UUID   `json:"id"`
+	Handle      string      `json:"handle"`
+	DisplayName string      `json:"display_name"`
+	Avatar      *avatarURLs `json:"avatar"`
+}
+
+// optionalViewer returns the user behind the bearer token, if the request
+// carries one.  A token that is present but invalid is still a 401.
+func (cfg *apiConfig) optionalViewer(w http.ResponseWriter, req *http.Request) (uuid.UUID, bool, bool) {
+	if req.Header.Get("Authorization") == "" {
+		return uuid.Nil, false, true
+	}
+	userID, ok := cfg.authenticate(w, req)
+	if !ok {
+		return uuid.Nil, false, false
+	}
+	return userID, true, true
+}
+
+// chirpResponses attaches authors, like and reply counts, image URLs,
+// liked_by_me when there is a viewer, and the original chirp of each
+// repost to a page of chirps, using one query for each.
+func (cfg *apiConfig) chirpResponses(ctx context.Context, chirps []database.Chirp, viewer uuid.UUID, hasViewer bool) ([]chirpResponse, error) {
+	resp, err := cfg.decorateChirps(ctx, chirps, viewer, hasViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	var originalIDs []uuid.UUID
+	for _, chirp := range chirps {
+		if chirp.RepostOf.Valid {
+			originalIDs = append(originalIDs, chirp.RepostOf.UUID)
+		}
+	}
+	originalByID := make(map[uuid.UUID]*chirpResponse, len(originalIDs))
+	if len(originalIDs) > 0 {
+		originals, err := cfg.db.GetChirpsByIDs(ctx, originalIDs)
+		if err != nil {
+			return nil, err
+		}
+		// Originals are decorated but not expanded further, so a quote of a
+		// quote only embeds one level.
+		decorated, err := cfg.decorateChirps(ctx, originals, viewer, hasViewer)
+		if err != nil {
+			return nil, err
+		}
+		for i := range decorated {
+			originalByID[decorated[i].ID] = &decorated[i]
+		}
+	}
+	for i, chirp := range chirps {
+		if !chirp.IsRepost {
+			continue
+		}
+		original, found := originalByID[chirp.RepostOf.UUID]
+		if chirp.RepostOf.Valid && found {
+			resp[i].Original = &repostOriginal{chirpResponse: original}
+		} else {
+			resp[i].Original = &repostOriginal{Deleted: true}
+		}
+	}
+	return resp, nil
+}
+
+func (cfg *apiConfig) decorateChirps(ctx context.Context, chirps []database.Chirp, viewer uuid.UUID, hasViewer bool) ([]chirpResponse, error) {
+	resp := make([]chirpResponse, len(chirps))
+	if len(chirps) == 0 {
+		return resp, nil
+	}
+	ids := make([]uuid.UUID, len(chirps))
+	for i, chirp := range chirps {
+		ids[i] = chirp.ID
+	}
+
+	counts, err := cfg.db.GetLikeCounts(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	countByID := make(map[uuid.UUID]int64, len(counts))
+	for _, row := range counts {
+		countByID[row.ChirpID] = row.LikeCount
+	}
+
+	replyCounts, err := cfg.db.GetReplyCounts(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	repliesByID := make(map[uuid.UUID]int64, len(replyCounts))
+	for _, row := range replyCounts {
+		repliesByID[row.ChirpID.UUID] = row.ReplyCount
+	}
+
+	authorIDs := make([]uuid.UUID, 0, len(chirps))
+	seenAuthor := make(map[uuid.UUID]bool, len(chirps))
+	for _, chirp := range chirps {
+		if !seenAuthor[chirp.UserID] {
+			seenAuthor[chirp.UserID] = true
+			authorIDs = append(authorIDs, chirp.UserID)
+		}
+	}
+	authors, err := cfg.db.GetChirpAuthors(ctx, authorIDs)
+	if err != nil {
+		return nil, err
+	}
+	authorByID := make(map[uuid.UUID]*chirpAuthor, len(authors))
+	for _, row := range authors {
+		authorByID[row.ID] = &chirpAuthor{
+			ID:          row.ID,
+			Handle:      row.Handle.String,
+			DisplayName: row.DisplayName,
+			Avatar:      newAvatarURLs(row.AvatarUrl48, row.AvatarUrl128, row.AvatarUrl400),
+		}
+	}
+
+	attached, err := cfg.db.GetChirpMedia(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	mediaByID := make(map[uuid.UUID][]mediaResponse, len(attached))
+	for _, row := range attached {
+		url, err := cfg.media.SignedURL(ctx, row.StorageKey, mediaURLLifetime)
+		if err != nil {
+			return nil, err
+		}
+		mediaByID[row.ChirpID] = append(mediaByID[row.ChirpID], mediaResponse{
+			URL:         url,
+			ContentType: row.ContentType,
+		})
+	}
+
+	var liked map[uuid.UUID]bool
+	if hasViewer {
+		likedIDs, err := cfg.db.GetLikedChirpIDs(ctx, database.GetLikedChirpIDsParams{
+			UserID:   viewer,
+			ChirpIds: ids,
+		})
+		if err != nil {
+			return nil, err
+		}
+		liked = make(map[uuid.UUID]bool, len(likedIDs))
+		for _, id := range likedIDs {
+			liked[id] = true
+		}
+	}
+
+	for i, chirp := range chirps {
+		resp[i] = chirpResponse{
+			Chirp:      chirp,
+			Author:     authorByID[chirp.UserID],
+			LikeCount:  countByID[chirp.ID],
+			ReplyCount: repliesByID[chirp.ID],
+			Media:      mediaByID[chirp.ID],
+		}
+		if resp[i].Media == nil {
+			resp[i].Media = []mediaResponse{}
 		}
-		if !added {
-			results = append(results, word)
+		if hasViewer {
+			likedByMe := liked[chirp.ID]
+			resp[i].LikedByMe = &likedByMe
 		}
 	}
-	return strings.Trim(strings.Join(results, " "), " ")
+	return resp, nil
 }
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// Error codes returned in the "code" field of every error response.  They
+// are part of the API contract: add new ones freely, but never rename one.
+const (
+	errCodeInvalidJSON         = "invalid_json"
+	errCodeInvalidID           = "invalid_id"
+	errCodeInvalidSort         = "invalid_sort"
+	errCodeInvalidPagination   = "invalid_pagination"
+	errCodeInvalidDate         = "invalid_date"
+	errCodeInvalidWindow       = "invalid_window"
+	errCodeMissingFields       = "missing_fields"
+	errCodeInvalidHandle       = "invalid_handle"
+	errCodeInvalidEmail        = "invalid_email"
+	errCodeInvalidEmailToken   = "invalid_verification_token"
+	errCodeInvalidResetToken   = "invalid_reset_token"
+	errCodeProfileTooLong      = "profile_too_long"
+	errCodeSelfFollow          = "self_follow"
+	errCodeChirpTooLong        = "chirp_too_long"
+	errCodeParentNotFound      = "parent_not_found"
+	errCodeProfanity           = "profanity"
+	errCodeInvalidWord         = "invalid_word"
+	errCodeInvalidForm         = "invalid_form"
+	errCodeTooManyImages       = "too_many_images"
+	errCodeInvalidImage        = "invalid_image"
+	errCodeUnauthorized        = "unauthorized"
+	errCodeInvalidCredentials  = "invalid_credentials"
+	errCodeInvalidRefreshToken = "invalid_refresh_token"
+	errCodeInvalidMFAToken     = "invalid_mfa_token"
+	errCodeInvalidMFACode      = "invalid_mfa_code"
+	errCodeInvalidAPIKey       = "invalid_api_key"
+	errCodeForbidden           = "forbidden"
+	errCodeEmailNotVerified    = "email_not_verified"
+	errCodeChirpNotFound       = "chirp_not_found"
+	errCodeUserNotFound        = "user_not_found"
+	errCodeWordNotFound        = "word_not_found"
+	errCodeNotFollowing        = "not_following"
+	errCodeNotLiked            = "not_liked"
+	errCodeEmailTaken          = "email_taken"
+	errCodeHandleTaken         = "handle_taken"
+	errCodeAlreadyVerified     = "already_verified"
+	errCodeAlreadyRechirped    = "already_rechirped"
+	errCodeMFAEnabled          = "mfa_already_enabled"
+	errCodeMFANotEnrolled      = "mfa_not_enrolled"
+	errCodeImageTooLarge       = "image_too_large"
+	errCodeUnsupportedMedia    = "unsupported_media_type"
+	errCodeAccountLocked       = "account_locked"
+	errCodeRateLimited         = "rate_limited"
+	errCodeTooManyAttempts     = "too_many_attempts"
+	errCodeInternal            = "internal_error"
+)
+
+type apiError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type errorEnvelope struct {
+	Error apiError `json:"error"`
+}
+
+func respondWithError(w http.ResponseWriter, status int, code string, msg string) {
+	out, err := json.Marshal(errorEnvelope{Error: apiError{Code: code, Message: msg}})
+	if err != nil {
+		log.Printf("An error occurred marshaling an error response: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(out)
+}
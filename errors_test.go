@@ -0,0 +1,976 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/interyx/chirpy/internal/auth"
+	"github.com/interyx/chirpy/internal/database"
+	"github.com/interyx/chirpy/internal/lockout"
+	"github.com/interyx/chirpy/internal/profanity"
+	"github.com/interyx/chirpy/internal/ratelimit"
+	"github.com/interyx/chirpy/internal/trending"
+)
+
+const testSecret = "test-signing-secret"
+
+func decodeError(t *testing.T, rec *httptest.ResponseRecorder) apiError {
+	t.Helper()
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("Expected Content-Type application/json, got %q", ct)
+	}
+	var envelope errorEnvelope
+	if err := json.NewDecoder(rec.Body).Decode(&envelope); err != nil {
+		t.Fatalf("Could not decode error envelope: %v", err)
+	}
+	return envelope.Error
+}
+
+// multipartForm builds a multipart body with an optional "body" field and
+// one fileField part per entry in files, and returns it with its
+// Content-Type header.
+func multipartForm(t *testing.T, body, fileField string, files ...[]byte) (string, map[string]string) {
+	t.Helper()
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	if body != "" {
+		if err := mw.WriteField("body", body); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	for i, file := range files {
+		part, err := mw.CreateFormFile(fileField, fmt.Sprintf("file%d", i))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		part.Write(file)
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return buf.String(), map[string]string{"Content-Type": mw.FormDataContentType()}
+}
+
+// handlerCase is a request that a handler should turn into an error
+// response with the given status and code.
+type handlerCase struct {
+	name       string
+	handler    http.HandlerFunc
+	method     string
+	target     string
+	body       string
+	headers    map[string]string
+	path       map[string]string
+	remoteAddr string
+	status     int
+	code       string
+}
+
+func runHandlerCases(t *testing.T, tests []handlerCase) {
+	t.Helper()
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body))
+			for k, v := range tc.headers {
+				req.Header.Set(k, v)
+			}
+			for k, v := range tc.path {
+				req.SetPathValue(k, v)
+			}
+			if tc.remoteAddr != "" {
+				req.RemoteAddr = tc.remoteAddr
+			}
+			rec := httptest.NewRecorder()
+			tc.handler(rec, req)
+
+			if rec.Code != tc.status {
+				t.Errorf("Expected status %d, got %d", tc.status, rec.Code)
+			}
+			if got := decodeError(t, rec); got.Code != tc.code {
+				t.Errorf("Expected code %s, got %s", tc.code, got.Code)
+			}
+		})
+	}
+}
+
+// databaseErrorCodes are the codes a handler can only produce after
+// reading or writing rows, so they are covered by
+// TestHandlerErrorCodesWithDatabase.
+var databaseErrorCodes = []string{
+	errCodeInvalidResetToken,
+	errCodeSelfFollow,
+	errCodeParentNotFound,
+	errCodeInvalidCredentials,
+	errCodeInvalidMFACode,
+	errCodeEmailNotVerified,
+	errCodeChirpNotFound,
+	errCodeUserNotFound,
+	errCodeWordNotFound,
+	errCodeNotFollowing,
+	errCodeNotLiked,
+	errCodeEmailTaken,
+	errCodeHandleTaken,
+	errCodeAlreadyVerified,
+	errCodeAlreadyRechirped,
+	errCodeMFAEnabled,
+	errCodeMFANotEnrolled,
+	errCodeAccountLocked,
+}
+
+// TestRespondWithError checks that every code declared in errors.go is
+// returned by at least one handler case.
+func TestRespondWithError(t *testing.T) {
+	file, err := parser.ParseFile(token.NewFileSet(), "errors.go", nil, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	covered := make(map[string]bool)
+	for _, tc := range handlerErrorCases(t) {
+		covered[tc.code] = true
+	}
+	for _, code := range databaseErrorCodes {
+		covered[code] = true
+	}
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.CONST {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			value := spec.(*ast.ValueSpec)
+			for i, name := range value.Names {
+				if !strings.HasPrefix(name.Name, "errCode") {
+					continue
+				}
+				code, err := strconv.Unquote(value.Values[i].(*ast.BasicLit).Value)
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				if !covered[code] {
+					t.Errorf("No handler case returns %s (%s)", name.Name, code)
+				}
+			}
+		}
+	}
+}
+
+// handlerErrorCases returns the error cases that need no database.
+func handlerErrorCases(t *testing.T) []handlerCase {
+	t.Helper()
+	cfg := &apiConfig{
+		platform:      "production",
+		signJWT:       testSecret,
+		polkaKey:      "polka-key",
+		adminKey:      "admin-key",
+		profanity:     profanity.New([]string{"kerfuffle"}),
+		profanityMode: profanity.ModeReject,
+		accountLogins: lockout.NewTracker(lockout.DefaultPolicy),
+		ipLogins:      lockout.NewTracker(ipLoginPolicy),
+		rateLimiter:   ratelimit.NewMemory(),
+	}
+	// httptest requests come from 192.0.2.1; push it past its free attempts.
+	for i := 0; i <= ipLoginPolicy.FreeAttempts; i++ {
+		cfg.ipLogins.Fail("ip:192.0.2.1", time.Now())
+	}
+	windows, err := trending.ParseWindows(trending.DefaultWindows)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	cfg.trending = trending.NewCache(windows, nil)
+	token, err := auth.MakeJWT(uuid.New(), testSecret, time.Hour)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	gif := []byte("GIF89a\x01\x00\x01\x00")
+	fiveImages, fiveImagesHeaders := multipartForm(t, "hello", "images", gif, gif, gif, gif, gif)
+	textUpload, textUploadHeaders := multipartForm(t, "hello", "images", []byte("not an image"))
+	brokenPNG, brokenPNGHeaders := multipartForm(t, "hello", "images", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\xffIHDR"))
+	gifAvatar, gifAvatarHeaders := multipartForm(t, "", "avatar", gif)
+	noAvatar, noAvatarHeaders := multipartForm(t, "hello", "images")
+	anonUpload, anonUploadHeaders := multipartForm(t, "hello", "images", []byte("not an image"))
+	bigAvatar, bigAvatarHeaders := multipartForm(t, "", "avatar", make([]byte, maxImageSize+1<<20))
+	for _, headers := range []map[string]string{fiveImagesHeaders, textUploadHeaders, brokenPNGHeaders, gifAvatarHeaders, noAvatarHeaders, bigAvatarHeaders} {
+		headers["Authorization"] = "Bearer " + token
+	}
+	// The only token in the bucket is already spent.
+	limit := ratelimit.Limit{Rate: 1.0 / 60, Burst: 1}
+	if _, err := cfg.rateLimiter.Take(context.Background(), "test:ip:192.0.2.1", limit, time.Now()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	limited := cfg.middlewareRateLimit("test", limit, clientIP, http.HandlerFunc(cfg.createChirpHandler))
+	// A closed pool fails every query the way an unreachable database does.
+	closed, err := sql.Open("postgres", "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	closed.Close()
+	broken := &apiConfig{db: database.New(closed)}
+	chirpID := uuid.NewString()
+
+	return []handlerCase{
+		{
+			name:    "Malformed signup body",
+			handler: cfg.addUser,
+			method:  "POST",
+			target:  "/api/users",
+			body:    "{",
+			status:  400,
+			code:    errCodeInvalidJSON,
+		},
+		{
+			name:    "Update with nothing to change",
+			handler: cfg.updateUser,
+			method:  "PUT",
+			target:  "/api/users",
+			body:    "{}",
+			headers: map[string]string{"Authorization": "Bearer " + token},
+			status:  400,
+			code:    errCodeMissingFields,
+		},
+		{
+			name:    "Signup with an invalid email",
+			handler: cfg.addUser,
+			method:  "POST",
+			target:  "/api/users",
+			body:    `{"email": "not an email", "password": "pw"}`,
+			status:  400,
+			code:    errCodeInvalidEmail,
+		},
+		{
+			name:    "Verify with a forged token",
+			handler: cfg.verifyEmailHandler,
+			method:  "GET",
+			target:  "/api/verify?token=not-a-token",
+			status:  400,
+			code:    errCodeInvalidEmailToken,
+		},
+		{
+			name:    "Verify with an access token",
+			handler: cfg.verifyEmailHandler,
+			method:  "GET",
+			target:  "/api/verify?token=" + token,
+			status:  400,
+			code:    errCodeInvalidEmailToken,
+		},
+		{
+			name:    "Resend verification without a bearer token",
+			handler: cfg.resendVerificationHandler,
+			method:  "POST",
+			target:  "/api/verify/resend",
+			status:  401,
+			code:    errCodeUnauthorized,
+		},
+		{
+			name:    "Forgot password without an email",
+			handler: cfg.forgotPasswordHandler,
+			method:  "POST",
+			target:  "/api/password/forgot",
+			body:    `{}`,
+			status:  400,
+			code:    errCodeMissingFields,
+		},
+		{
+			name:    "Reset password without a token",
+			handler: cfg.resetPasswordHandler,
+			method:  "POST",
+			target:  "/api/password/reset",
+			body:    `{"password": "hunter2"}`,
+			status:  400,
+			code:    errCodeMissingFields,
+		},
+		{
+			name:    "Malformed reset body",
+			handler: cfg.resetPasswordHandler,
+			method:  "POST",
+			target:  "/api/password/reset",
+			body:    "{",
+			status:  400,
+			code:    errCodeInvalidJSON,
+		},
+		{
+			name:    "Signup with a reserved handle",
+			handler: cfg.addUser,
+			method:  "POST",
+			target:  "/api/users",
+			body:    `{"email": "a@example.com", "password": "pw", "handle": "admin"}`,
+			status:  400,
+			code:    errCodeInvalidHandle,
+		},
+		{
+			name:    "Update with a handle that is too short",
+			handler: cfg.updateUser,
+			method:  "PUT",
+			target:  "/api/users",
+			body:    `{"handle": "ab"}`,
+			headers: map[string]string{"Authorization": "Bearer " + token},
+			status:  400,
+			code:    errCodeInvalidHandle,
+		},
+		{
+			name:    "Update with a bio that is too long",
+			handler: cfg.updateUser,
+			method:  "PUT",
+			target:  "/api/users",
+			body:    `{"bio": "` + strings.Repeat("b", 161) + `"}`,
+			headers: map[string]string{"Authorization": "Bearer " + token},
+			status:  400,
+			code:    errCodeProfileTooLong,
+		},
+		{
+			name:    "Login from a throttled address",
+			handler: cfg.loginHandler,
+			method:  "POST",
+			target:  "/api/login",
+			body:    `{"email": "a@example.com", "password": "pw"}`,
+			status:  429,
+			code:    errCodeTooManyAttempts,
+		},
+		{
+			name:    "MFA login without a code",
+			handler: cfg.loginMFAHandler,
+			method:  "POST",
+			target:  "/api/login/mfa",
+			body:    `{"mfa_token": "abc"}`,
+			status:  400,
+			code:    errCodeMissingFields,
+		},
+		{
+			name:    "MFA login from a throttled address",
+			handler: cfg.loginMFAHandler,
+			method:  "POST",
+			target:  "/api/login/mfa",
+			body:    `{"mfa_token": "abc", "code": "123456"}`,
+			status:  429,
+			code:    errCodeTooManyAttempts,
+		},
+		{
+			name:       "MFA login with a forged token",
+			handler:    cfg.loginMFAHandler,
+			method:     "POST",
+			target:     "/api/login/mfa",
+			body:       `{"mfa_token": "not-a-token", "code": "123456"}`,
+			remoteAddr: "198.51.100.7:1234",
+			status:     401,
+			code:       errCodeInvalidMFAToken,
+		},
+		{
+			name:    "MFA enrollment without a bearer token",
+			handler: cfg.enrollMFAHandler,
+			method:  "POST",
+			target:  "/api/users/mfa",
+			status:  401,
+			code:    errCodeUnauthorized,
+		},
+		{
+			name:    "MFA confirmation without a code",
+			handler: cfg.confirmMFAHandler,
+			method:  "POST",
+			target:  "/api/users/mfa/confirm",
+			body:    `{}`,
+			headers: map[string]string{"Authorization": "Bearer " + token},
+			status:  400,
+			code:    errCodeMissingFields,
+		},
+		{
+			name:    "Turning MFA off without a bearer token",
+			handler: cfg.disableMFAHandler,
+			method:  "DELETE",
+			target:  "/api/users/mfa",
+			body:    `{"code": "123456"}`,
+			status:  401,
+			code:    errCodeUnauthorized,
+		},
+		{
+			name:    "Unlock without an API key",
+			handler: cfg.unlockUserHandler,
+			method:  "POST",
+			target:  "/admin/users/" + uuid.NewString() + "/unlock",
+			status:  401,
+			code:    errCodeInvalidAPIKey,
+		},
+		{
+			name:    "Unlock with an invalid user ID",
+			handler: cfg.unlockUserHandler,
+			method:  "POST",
+			target:  "/admin/users/nope/unlock",
+			headers: map[string]string{"Authorization": "ApiKey admin-key"},
+			path:    map[string]string{"id": "nope"},
+			status:  400,
+			code:    errCodeInvalidID,
+		},
+		{
+			name:    "Chirp over 140 characters",
+			handler: cfg.createChirpHandler,
+			method:  "POST",
+			target:  "/api/chirps",
+			body:    `{"body": "` + strings.Repeat("a", 141) + `"}`,
+			headers: map[string]string{"Authorization": "Bearer " + token},
+			status:  400,
+			code:    errCodeChirpTooLong,
+		},
+		{
+			name:    "Chirp with a banned word in reject mode",
+			handler: cfg.createChirpHandler,
+			method:  "POST",
+			target:  "/api/chirps",
+			body:    `{"body": "what a kerfuffle!"}`,
+			headers: map[string]string{"Authorization": "Bearer " + token},
+			status:  400,
+			code:    errCodeProfanity,
+		},
+		{
+			name:    "Chirp with five images",
+			handler: cfg.createChirpHandler,
+			method:  "POST",
+			target:  "/api/chirps",
+			body:    fiveImages,
+			headers: fiveImagesHeaders,
+			status:  400,
+			code:    errCodeTooManyImages,
+		},
+		{
+			name:    "Chirp with a file that is not an image",
+			handler: cfg.createChirpHandler,
+			method:  "POST",
+			target:  "/api/chirps",
+			body:    textUpload,
+			headers: textUploadHeaders,
+			status:  415,
+			code:    errCodeUnsupportedMedia,
+		},
+		{
+			name:    "Chirp with a truncated PNG",
+			handler: cfg.createChirpHandler,
+			method:  "POST",
+			target:  "/api/chirps",
+			body:    brokenPNG,
+			headers: brokenPNGHeaders,
+			status:  400,
+			code:    errCodeInvalidImage,
+		},
+		{
+			name:    "Chirp upload without a bearer token",
+			handler: cfg.createChirpHandler,
+			method:  "POST",
+			target:  "/api/chirps",
+			body:    anonUpload,
+			headers: anonUploadHeaders,
+			status:  401,
+			code:    errCodeUnauthorized,
+		},
+		{
+			name:    "Avatar without a bearer token",
+			handler: cfg.uploadAvatarHandler,
+			method:  "PUT",
+			target:  "/api/users/avatar",
+			status:  401,
+			code:    errCodeUnauthorized,
+		},
+		{
+			name:    "Avatar form without a file",
+			handler: cfg.uploadAvatarHandler,
+			method:  "PUT",
+			target:  "/api/users/avatar",
+			body:    noAvatar,
+			headers: noAvatarHeaders,
+			status:  400,
+			code:    errCodeInvalidForm,
+		},
+		{
+			name:    "Avatar that is a GIF",
+			handler: cfg.uploadAvatarHandler,
+			method:  "PUT",
+			target:  "/api/users/avatar",
+			body:    gifAvatar,
+			headers: gifAvatarHeaders,
+			status:  415,
+			code:    errCodeUnsupportedMedia,
+		},
+		{
+			name:    "Avatar over the size limit",
+			handler: cfg.uploadAvatarHandler,
+			method:  "PUT",
+			target:  "/api/users/avatar",
+			body:    bigAvatar,
+			headers: bigAvatarHeaders,
+			status:  413,
+			code:    errCodeImageTooLarge,
+		},
+		{
+			name:    "Chirp without a bearer token",
+			handler: cfg.createChirpHandler,
+			method:  "POST",
+			target:  "/api/chirps",
+			body:    `{"body": "hello"}`,
+			status:  401,
+			code:    errCodeUnauthorized,
+		},
+		{
+			name:    "Chirp with a bad bearer token",
+			handler: cfg.createChirpHandler,
+			method:  "POST",
+			target:  "/api/chirps",
+			body:    `{"body": "hello"}`,
+			headers: map[string]string{"Authorization": "Bearer not-a-jwt"},
+			status:  401,
+			code:    errCodeUnauthorized,
+		},
+		{
+			name:    "Chirp past the rate limit",
+			handler: limited.ServeHTTP,
+			method:  "POST",
+			target:  "/api/chirps",
+			body:    `{"body": "hello"}`,
+			headers: map[string]string{"Authorization": "Bearer " + token},
+			status:  429,
+			code:    errCodeRateLimited,
+		},
+		{
+			name:    "Chirp ID is not a UUID",
+			handler: cfg.getChirpHandler,
+			method:  "GET",
+			target:  "/api/chirps/nope",
+			path:    map[string]string{"id": "nope"},
+			status:  400,
+			code:    errCodeInvalidID,
+		},
+		{
+			name:    "Chirp lookup with the database down",
+			handler: broken.getChirpHandler,
+			method:  "GET",
+			target:  "/api/chirps/" + chirpID,
+			path:    map[string]string{"id": chirpID},
+			status:  500,
+			code:    errCodeInternal,
+		},
+		{
+			name:    "Author ID is not a UUID",
+			handler: cfg.getChirpsHandler,
+			method:  "GET",
+			target:  "/api/chirps?author_id=nope",
+			status:  400,
+			code:    errCodeInvalidID,
+		},
+		{
+			name:    "Chirp listing with a bad bearer token",
+			handler: cfg.getChirpsHandler,
+			method:  "GET",
+			target:  "/api/chirps",
+			headers: map[string]string{"Authorization": "Bearer not-a-jwt"},
+			status:  401,
+			code:    errCodeUnauthorized,
+		},
+		{
+			name:    "Unknown sort order",
+			handler: cfg.getChirpsHandler,
+			method:  "GET",
+			target:  "/api/chirps?sort=sideways",
+			status:  400,
+			code:    errCodeInvalidSort,
+		},
+		{
+			name:    "Limit out of range",
+			handler: cfg.getChirpsHandler,
+			method:  "GET",
+			target:  "/api/chirps?limit=0",
+			status:  400,
+			code:    errCodeInvalidPagination,
+		},
+		{
+			name:    "Garbage cursor",
+			handler: cfg.getChirpsHandler,
+			method:  "GET",
+			target:  "/api/chirps?cursor=%21%21",
+			status:  400,
+			code:    errCodeInvalidPagination,
+		},
+		{
+			name:    "Timeline without a bearer token",
+			handler: cfg.timelineHandler,
+			method:  "GET",
+			target:  "/api/timeline",
+			status:  401,
+			code:    errCodeUnauthorized,
+		},
+		{
+			name:    "Follow a user ID that is not a UUID",
+			handler: cfg.followHandler,
+			method:  "POST",
+			target:  "/api/users/nope/follow",
+			headers: map[string]string{"Authorization": "Bearer " + token},
+			path:    map[string]string{"id": "nope"},
+			status:  400,
+			code:    errCodeInvalidID,
+		},
+		{
+			name:    "Search without a query",
+			handler: cfg.searchChirpsHandler,
+			method:  "GET",
+			target:  "/api/chirps/search?q=+",
+			status:  400,
+			code:    errCodeMissingFields,
+		},
+		{
+			name:    "Search with a malformed date",
+			handler: cfg.searchChirpsHandler,
+			method:  "GET",
+			target:  "/api/chirps/search?q=fornax&since=yesterday",
+			status:  400,
+			code:    errCodeInvalidDate,
+		},
+		{
+			name:    "Search with a negative offset",
+			handler: cfg.searchChirpsHandler,
+			method:  "GET",
+			target:  "/api/chirps/search?q=fornax&offset=-1",
+			status:  400,
+			code:    errCodeInvalidPagination,
+		},
+		{
+			name:    "Unknown trending window",
+			handler: cfg.trendingHandler,
+			method:  "GET",
+			target:  "/api/trending?window=2w",
+			status:  400,
+			code:    errCodeInvalidWindow,
+		},
+		{
+			name:    "Trending limit too large",
+			handler: cfg.trendingHandler,
+			method:  "GET",
+			target:  "/api/trending?limit=500",
+			status:  400,
+			code:    errCodeInvalidPagination,
+		},
+		{
+			name:    "Refresh without a token",
+			handler: cfg.refreshHandler,
+			method:  "POST",
+			target:  "/api/refresh",
+			status:  401,
+			code:    errCodeInvalidRefreshToken,
+		},
+		{
+			name:    "Webhook with the wrong API key",
+			handler: cfg.polkaWebhookHandler,
+			method:  "POST",
+			target:  "/api/polka/webhooks",
+			body:    `{"event": "user.upgraded"}`,
+			headers: map[string]string{"Authorization": "ApiKey wrong"},
+			status:  401,
+			code:    errCodeInvalidAPIKey,
+		},
+		{
+			name:    "Banned word list without the admin key",
+			handler: cfg.getBannedWordsHandler,
+			method:  "GET",
+			target:  "/admin/banned-words",
+			status:  401,
+			code:    errCodeInvalidAPIKey,
+		},
+		{
+			name:    "Banning more than one word",
+			handler: cfg.addBannedWordHandler,
+			method:  "POST",
+			target:  "/admin/banned-words",
+			body:    `{"word": "two words"}`,
+			headers: map[string]string{"Authorization": "ApiKey admin-key"},
+			status:  400,
+			code:    errCodeInvalidWord,
+		},
+		{
+			name:    "Reset outside of dev",
+			handler: cfg.resetHandler,
+			method:  "POST",
+			target:  "/admin/reset",
+			status:  400,
+			code:    errCodeForbidden,
+		},
+	}
+}
+
+func TestHandlerErrorCodes(t *testing.T) {
+	runHandlerCases(t, handlerErrorCases(t))
+}
+
+func TestHandlerErrorCodesWithDatabase(t *testing.T) {
+	db, queries := openTestDB(t)
+	ctx := context.Background()
+	now := time.Now()
+	cfg := &apiConfig{
+		db:            queries,
+		sqlDB:         db,
+		signJWT:       testSecret,
+		adminKey:      "admin-key",
+		profanity:     profanity.New(nil),
+		accountLogins: lockout.NewTracker(lockout.DefaultPolicy),
+		ipLogins:      lockout.NewTracker(ipLoginPolicy),
+	}
+	bearer := func(user database.User) map[string]string {
+		token, err := auth.MakeJWT(user.ID, testSecret, time.Hour)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return map[string]string{"Authorization": "Bearer " + token}
+	}
+
+	unverified := createTestUser(t, db, queries, "unused")
+	verified := createTestUser(t, db, queries, "unused")
+	takenHandle := "h" + strings.ReplaceAll(uuid.NewString(), "-", "")[:12]
+	verified, err := queries.UpdateUser(ctx, database.UpdateUserParams{
+		ID:             verified.ID,
+		Email:          verified.Email,
+		HashedPassword: verified.HashedPassword,
+		UpdatedAt:      now,
+		Handle:         sql.NullString{String: takenHandle, Valid: true},
+		EmailVerified:  true,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	withMFA := createTestUser(t, db, queries, "unused")
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	err = queries.SetTOTPSecret(ctx, database.SetTOTPSecretParams{ID: withMFA.ID, TotpSecret: secret, UpdatedAt: now})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	err = queries.EnableTOTP(ctx, database.EnableTOTPParams{ID: withMFA.ID, UpdatedAt: now})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	staleCode, err := auth.TOTPCode(secret, now.Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	original, err := queries.CreateChirp(ctx, database.CreateChirpParams{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		Body:      "hello",
+		UserID:    unverified.ID,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	_, err = queries.CreateChirp(ctx, database.CreateChirpParams{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		UserID:    verified.ID,
+		RepostOf:  uuid.NullUUID{UUID: original.ID, Valid: true},
+		IsRepost:  true,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	missing := uuid.NewString()
+	word := "zzq" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	lockedEmail := uuid.NewString() + "@example.com"
+	for i := 0; i < lockout.DefaultPolicy.LockAfter; i++ {
+		cfg.accountLogins.Fail(unknownEmailKey(lockedEmail), now)
+	}
+
+	tests := []handlerCase{
+		{
+			name:    "Reset with an unknown token",
+			handler: cfg.resetPasswordHandler,
+			method:  "POST",
+			target:  "/api/password/reset",
+			body:    `{"token": "not-a-token", "password": "hunter2"}`,
+			status:  400,
+			code:    errCodeInvalidResetToken,
+		},
+		{
+			name:    "Follow yourself",
+			handler: cfg.followHandler,
+			method:  "POST",
+			target:  "/api/users/" + unverified.ID.String() + "/follow",
+			headers: bearer(unverified),
+			path:    map[string]string{"id": unverified.ID.String()},
+			status:  400,
+			code:    errCodeSelfFollow,
+		},
+		{
+			name:    "Reply to a chirp that does not exist",
+			handler: cfg.createChirpHandler,
+			method:  "POST",
+			target:  "/api/chirps",
+			body:    `{"body": "hello", "in_reply_to": "` + missing + `"}`,
+			headers: bearer(verified),
+			status:  400,
+			code:    errCodeParentNotFound,
+		},
+		{
+			name:    "Login with the wrong password",
+			handler: cfg.loginHandler,
+			method:  "POST",
+			target:  "/api/login",
+			body:    `{"email": "` + unverified.Email + `", "password": "wrong"}`,
+			status:  401,
+			code:    errCodeInvalidCredentials,
+		},
+		{
+			name:    "Turning MFA off with a stale code",
+			handler: cfg.disableMFAHandler,
+			method:  "DELETE",
+			target:  "/api/users/mfa",
+			body:    `{"code": "` + staleCode + `"}`,
+			headers: bearer(withMFA),
+			status:  401,
+			code:    errCodeInvalidMFACode,
+		},
+		{
+			name:    "Chirp before confirming the email",
+			handler: cfg.createChirpHandler,
+			method:  "POST",
+			target:  "/api/chirps",
+			body:    `{"body": "hello"}`,
+			headers: bearer(unverified),
+			status:  403,
+			code:    errCodeEmailNotVerified,
+		},
+		{
+			name:    "Chirp that does not exist",
+			handler: cfg.getChirpHandler,
+			method:  "GET",
+			target:  "/api/chirps/" + missing,
+			path:    map[string]string{"id": missing},
+			status:  404,
+			code:    errCodeChirpNotFound,
+		},
+		{
+			name:    "Followers of a user that does not exist",
+			handler: cfg.getFollowersHandler,
+			method:  "GET",
+			target:  "/api/users/" + missing + "/followers",
+			path:    map[string]string{"id": missing},
+			status:  404,
+			code:    errCodeUserNotFound,
+		},
+		{
+			name:    "Unban a word that is not banned",
+			handler: cfg.deleteBannedWordHandler,
+			method:  "DELETE",
+			target:  "/admin/banned-words/" + word,
+			headers: map[string]string{"Authorization": "ApiKey admin-key"},
+			path:    map[string]string{"word": word},
+			status:  404,
+			code:    errCodeWordNotFound,
+		},
+		{
+			name:    "Unfollow a user you do not follow",
+			handler: cfg.unfollowHandler,
+			method:  "DELETE",
+			target:  "/api/users/" + verified.ID.String() + "/follow",
+			headers: bearer(unverified),
+			path:    map[string]string{"id": verified.ID.String()},
+			status:  404,
+			code:    errCodeNotFollowing,
+		},
+		{
+			name:    "Unlike a chirp you have not liked",
+			handler: cfg.unlikeChirpHandler,
+			method:  "DELETE",
+			target:  "/api/chirps/" + missing + "/like",
+			headers: bearer(unverified),
+			path:    map[string]string{"id": missing},
+			status:  404,
+			code:    errCodeNotLiked,
+		},
+		{
+			name:    "Update to an email that is taken",
+			handler: cfg.updateUser,
+			method:  "PUT",
+			target:  "/api/users",
+			body:    `{"email": "` + verified.Email + `"}`,
+			headers: bearer(unverified),
+			status:  409,
+			code:    errCodeEmailTaken,
+		},
+		{
+			name:    "Update to a handle that is taken",
+			handler: cfg.updateUser,
+			method:  "PUT",
+			target:  "/api/users",
+			body:    `{"handle": "` + strings.ToUpper(takenHandle) + `"}`,
+			headers: bearer(unverified),
+			status:  409,
+			code:    errCodeHandleTaken,
+		},
+		{
+			name:    "Resend verification once confirmed",
+			handler: cfg.resendVerificationHandler,
+			method:  "POST",
+			target:  "/api/verify/resend",
+			headers: bearer(verified),
+			status:  409,
+			code:    errCodeAlreadyVerified,
+		},
+		{
+			name:    "Rechirp the same chirp twice",
+			handler: cfg.rechirpHandler,
+			method:  "POST",
+			target:  "/api/chirps/" + original.ID.String() + "/rechirp",
+			headers: bearer(verified),
+			path:    map[string]string{"id": original.ID.String()},
+			status:  409,
+			code:    errCodeAlreadyRechirped,
+		},
+		{
+			name:    "Enroll in MFA twice",
+			handler: cfg.enrollMFAHandler,
+			method:  "POST",
+			target:  "/api/users/mfa",
+			headers: bearer(withMFA),
+			status:  409,
+			code:    errCodeMFAEnabled,
+		},
+		{
+			name:    "Confirm MFA without enrolling",
+			handler: cfg.confirmMFAHandler,
+			method:  "POST",
+			target:  "/api/users/mfa/confirm",
+			body:    `{"code": "123456"}`,
+			headers: bearer(unverified),
+			status:  409,
+			code:    errCodeMFANotEnrolled,
+		},
+		{
+			name:       "Login to a locked email",
+			handler:    cfg.loginHandler,
+			method:     "POST",
+			target:     "/api/login",
+			body:       `{"email": "` + lockedEmail + `", "password": "pw"}`,
+			remoteAddr: "198.51.100.7:1234",
+			status:     423,
+			code:       errCodeAccountLocked,
+		},
+	}
+	covered := make(map[string]bool)
+	for _, tc := range tests {
+		covered[tc.code] = true
+	}
+	for _, code := range databaseErrorCodes {
+		if !covered[code] {
+			t.Errorf("No database case returns %s", code)
+		}
+	}
+	runHandlerCases(t, tests)
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/interyx/chirpy/internal/database"
+)
+
+// followTarget parses the {id} path value and checks that the user exists.
+func (cfg *apiConfig) followTarget(w http.ResponseWriter, req *http.Request) (uuid.UUID, bool) {
+	id, err := uuid.Parse(req.PathValue("id"))
+	if err != nil {
+		respondWithError(w, 400, errCodeInvalidID, "User ID is not a valid UUID")
+		return uuid.Nil, false
+	}
+	_, err = cfg.db.GetUserByID(req.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 404, errCodeUserNotFound, "User not found")
+		return uuid.Nil, false
+	}
+	if err != nil {
+		log.Printf("An error occurred retrieving the user: %s", err)
+		respondWithError(w, 500, errCodeInternal, "An error occurred retrieving the user")
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func (cfg *apiConfig) followHandler(w http.ResponseWriter, req *http.Request) {
+	userID, ok := cfg.authenticate(w, req)
+	if !ok {
+		return
+	}
+	followeeID, ok := cfg.followTarget(w, req)
+	if !ok {
+		return
+	}
+	if followeeID == userID {
+		respondWithError(w, 400, errCodeSelfFollow, "You cannot follow yourself")
+		return
+	}
+	followParams := database.FollowUserParams{
+		FollowerID: userID,
+		FolloweeID: followeeID,
+		CreatedAt:  time.Now(),
+	}
+	_, err := cfg.db.FollowUser(req.Context(), followParams)
+	if err != nil {
+		log.Printf("An error occurred following the user: %s", err)
+		respondWithError(w, 500, errCodeInternal, "An error occurred following the user")
+		return
+	}
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) unfollowHandler(w http.ResponseWriter, req *http.Request) {
+	userID, ok := cfg.authenticate(w, req)
+	if !ok {
+		return
+	}
+	followeeID, err := uuid.Parse(req.PathValue("id"))
+	if err != nil {
+		respondWithError(w, 400, errCodeInvalidID, "User ID is not a valid UUID")
+		return
+	}
+	unfollowParams := database.UnfollowUserParams{
+		FollowerID: userID,
+		FolloweeID: followeeID,
+	}
+	rows, err := cfg.db.UnfollowUser(req.Context(), unfollowParams)
+	if err != nil {
+		log.Printf("An error occurred unfollowing the user: %s", err)
+		respondWithError(w, 500, errCodeInternal, "An error occurred unfollowing the user")
+		return
+	}
+	if rows == 0 {
+		respondWithError(w, 404, errCodeNotFollowing, "You are not following that user")
+		return
+	}
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) getFollowersHandler(w http.ResponseWriter, req *http.Request) {
+	id, ok := cfg.followTarget(w, req)
+	if !ok {
+		return
+	}
+	limit, cursor, err := pageParams(req, true)
+	if err != nil {
+		respondWithError(w, 400, errCodeInvalidPagination, err.Error())
+		return
+	}
+	// Ask for one extra row to learn whether another page follows.
+	followers, err := cfg.db.GetFollowers(req.Context(), database.GetFollowersParams{
+		FolloweeID:      id,
+		CursorCreatedAt: cursor.CreatedAt,
+		CursorID:        cursor.ID,
+		PageSize:        limit + 1,
+	})
+	if err != nil {
+		log.Printf("An error occurred retrieving followers: %s", err)
+		respondWithError(w, 500, errCodeInternal, "An error occurred retrieving followers")
+		return
+	}
+	if len(followers) > int(limit) {
+		followers = followers[:limit]
+		last := followers[len(followers)-1]
+		setNextLink(w, req, pageCursor{CreatedAt: last.FollowedAt, ID: last.UserID})
+	}
+	if followers == nil {
+		followers = []database.GetFollowersRow{}
+	}
+	out, err := json.Marshal(followers)
+	if err != nil {
+		respondWithError(w, 500, errCodeInternal, "A marshaling error occurred")
+		return
+	}
+	respondWithJSON(w, 200, out)
+}
+
+func (cfg *apiConfig) getFollowingHandler(w http.ResponseWriter, req *http.Request) {
+	id, ok := cfg.followTarget(w, req)
+	if !ok {
+		return
+	}
+	limit, cursor, err := pageParams(req, true)
+	if err != nil {
+		respondWithError(w, 400, errCodeInvalidPagination, err.Error())
+		return
+	}
+	// Ask for one extra row to learn whether another page follows.
+	following, err := cfg.db.GetFollowing(req.Context(), database.GetFollowingParams{
+		FollowerID:      id,
+		CursorCreatedAt: cursor.CreatedAt,
+		CursorID:        cursor.ID,
+		PageSize:        limit + 1,
+	})
+	if err != nil {
+		log.Printf("An error occurred retrieving followed users: %s", err)
+		respondWithError(w, 500, errCodeInternal, "An error occurred retrieving followed users")
+		return
+	}
+	if len(following) > int(limit) {
+		following = following[:limit]
+		last := following[len(following)-1]
+		setNextLink(w, req, pageCursor{CreatedAt: last.FollowedAt, ID: last.UserID})
+	}
+	if following == nil {
+		following = []database.GetFollowingRow{}
+	}
+	out, err := json.Marshal(following)
+	if err != nil {
+		respondWithError(w, 500, errCodeInternal, "A marshaling error occurred")
+		return
+	}
+	respondWithJSON(w, 200, out)
+}
+
+func (cfg *apiConfig) timelineHandler(w http.ResponseWriter, req *http.Request) {
+	userID, ok := cfg.authenticate(w, req)
+	if !ok {
+		return
+	}
+	limit, cursor, err := pageParams(req, true)
+	if err != nil {
+		respondWithError(w, 400, errCodeInvalidPagination, err.Error())
+		return
+	}
+	// Ask for one extra row to learn whether another page follows.
+	chirps, err := cfg.db.GetTimeline(req.Context(), database.GetTimelineParams{
+		FollowerID:      userID,
+		CursorCreatedAt: cursor.CreatedAt,
+		CursorID:        cursor.ID,
+		PageSize:        limit + 1,
+	})
+	if err != nil {
+		log.Printf("An error occurred getting the timeline: %s", err)
+		respondWithError(w, 500, errCodeInternal, "An error occurred retrieving the timeline")
+		return
+	}
+	cfg.writeChirpPage(w, req, chirps, limit, userID, true)
+}
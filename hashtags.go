@@ -0,0 +1,70 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/interyx/chirpy/internal/database"
+)
+
+func (cfg *apiConfig) getHashtagChirpsHandler(w http.ResponseWriter, req *http.Request) {
+	viewer, hasViewer, ok := cfg.optionalViewer(w, req)
+	if !ok {
+		return
+	}
+	tag := strings.ToLower(strings.TrimPrefix(req.PathValue("tag"), "#"))
+	if tag == "" {
+		respondWithError(w, 400, errCodeMissingFields, "A hashtag is required")
+		return
+	}
+	limit, cursor, err := pageParams(req, true)
+	if err != nil {
+		respondWithError(w, 400, errCodeInvalidPagination, err.Error())
+		return
+	}
+	// Ask for one extra row to learn whether another page follows.
+	chirps, err := cfg.db.GetChirpsByHashtag(req.Context(), database.GetChirpsByHashtagParams{
+		Tag:             tag,
+		CursorCreatedAt: cursor.CreatedAt,
+		CursorID:        cursor.ID,
+		PageSize:        limit + 1,
+	})
+	if err != nil {
+		log.Printf("An error occurred getting chirps tagged %s: %s", tag, err)
+		respondWithError(w, 500, errCodeInternal, "An error occurred retrieving chirps")
+		return
+	}
+	cfg.writeChirpPage(w, req, chirps, limit, viewer, hasViewer)
+}
+
+func (cfg *apiConfig) getMentionsHandler(w http.ResponseWriter, req *http.Request) {
+	viewer, hasViewer, ok := cfg.optionalViewer(w, req)
+	if !ok {
+		return
+	}
+	id, err := uuid.Parse(req.PathValue("id"))
+	if err != nil {
+		respondWithError(w, 400, errCodeInvalidID, "User ID is not a valid UUID")
+		return
+	}
+	limit, cursor, err := pageParams(req, true)
+	if err != nil {
+		respondWithError(w, 400, errCodeInvalidPagination, err.Error())
+		return
+	}
+	// Ask for one extra row to learn whether another page follows.
+	chirps, err := cfg.db.GetMentions(req.Context(), database.GetMentionsParams{
+		UserID:          id,
+		CursorCreatedAt: cursor.CreatedAt,
+		CursorID:        cursor.ID,
+		PageSize:        limit + 1,
+	})
+	if err != nil {
+		log.Printf("An error occurred getting mentions of %s: %s", id, err)
+		respondWithError(w, 500, errCodeInternal, "An error occurred retrieving mentions")
+		return
+	}
+	cfg.writeChirpPage(w, req, chirps, limit, viewer, hasViewer)
+}
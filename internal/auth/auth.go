@@ -2,7 +2,9 @@ package auth
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -13,6 +15,12 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+var (
+	ErrRefreshTokenExpired = errors.New("refresh token has expired")
+	ErrRefreshTokenRevoked = errors.New("refresh token has been revoked")
+	ErrRefreshTokenReused  = errors.New("refresh token has already been used")
+)
+
 func HashPassword(password string) (string, error) {
 	hash, err := bcrypt.GenerateFromPassword([]byte(password), 6)
 	if err != nil {
@@ -43,9 +51,11 @@ func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (str
 }
 
 func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
+	// The issuer check keeps other tokens signed with the same secret,
+	// such as email verification links, from working as access tokens.
 	token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) {
 		return []byte(tokenSecret), nil
-	})
+	}, jwt.WithIssuer("chirpy"))
 	if err != nil {
 		return uuid.Nil, err
 	}
@@ -57,6 +67,42 @@ func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
 	return id, nil
 }
 
+const emailTokenIssuer = "chirpy-email-verification"
+
+// MakeEmailToken signs a verification link token for userID.  tokenID
+// goes in the jti claim so the caller can make the token single-use.
+func MakeEmailToken(userID, tokenID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
+	claims := jwt.RegisteredClaims{
+		Issuer:    emailTokenIssuer,
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
+		Subject:   userID.String(),
+		ID:        tokenID.String(),
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(tokenSecret))
+}
+
+// ValidateEmailToken checks the signature and expiry of a token from
+// MakeEmailToken and returns its user and token IDs.
+func ValidateEmailToken(tokenString, tokenSecret string) (uuid.UUID, uuid.UUID, error) {
+	claims := &jwt.RegisteredClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(tokenSecret), nil
+	}, jwt.WithIssuer(emailTokenIssuer), jwt.WithValidMethods([]string{"HS256"}))
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+	tokenID, err := uuid.Parse(claims.ID)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+	return userID, tokenID, nil
+}
+
 func GetBearerToken(headers http.Header) (string, error) {
 	header := headers.Get("authorization")
 	if header == "" {
@@ -66,6 +112,18 @@ func GetBearerToken(headers http.Header) (string, error) {
 	return ans, nil
 }
 
+func GetAPIKey(headers http.Header) (string, error) {
+	header := headers.Get("authorization")
+	if header == "" {
+		return "", fmt.Errorf("Authorization header not found")
+	}
+	key, found := strings.CutPrefix(header, "ApiKey ")
+	if !found {
+		return "", fmt.Errorf("Authorization header is not an API key")
+	}
+	return key, nil
+}
+
 func MakeRefreshToken() (string, error) {
 	digits := make([]byte, 32)
 	_, err := rand.Read(digits)
@@ -74,3 +132,26 @@ func MakeRefreshToken() (string, error) {
 	}
 	return hex.EncodeToString(digits), nil
 }
+
+// HashToken returns the SHA-256 of a random token, hex-encoded, for
+// storing tokens that only need to be matched, never read back.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CheckRefreshToken reports whether a stored refresh token can still be
+// exchanged. A token that was rotated being presented again is reported
+// as reuse; one that was only revoked, say by logging out, is not.
+func CheckRefreshToken(expiresAt time.Time, revoked, rotated bool) error {
+	if rotated {
+		return ErrRefreshTokenReused
+	}
+	if revoked {
+		return ErrRefreshTokenRevoked
+	}
+	if !time.Now().Before(expiresAt) {
+		return ErrRefreshTokenExpired
+	}
+	return nil
+}
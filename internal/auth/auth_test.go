@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"testing"
@@ -97,3 +98,146 @@ func TestValidateJWT(t *testing.T) {
 		}
 	})
 }
+
+func TestCheckRefreshToken(t *testing.T) {
+	t.Run("Active refresh token", func(t *testing.T) {
+		err := CheckRefreshToken(time.Now().Add(time.Hour), false, false)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Expired refresh token", func(t *testing.T) {
+		err := CheckRefreshToken(time.Now().Add(-time.Hour), false, false)
+		if !errors.Is(err, ErrRefreshTokenExpired) {
+			t.Fatalf("Expected ErrRefreshTokenExpired, got %v", err)
+		}
+	})
+
+	t.Run("Rotated refresh token presented again", func(t *testing.T) {
+		err := CheckRefreshToken(time.Now().Add(time.Hour), true, true)
+		if !errors.Is(err, ErrRefreshTokenReused) {
+			t.Fatalf("Expected ErrRefreshTokenReused, got %v", err)
+		}
+	})
+
+	t.Run("Revoked refresh token presented again", func(t *testing.T) {
+		err := CheckRefreshToken(time.Now().Add(time.Hour), true, false)
+		if !errors.Is(err, ErrRefreshTokenRevoked) {
+			t.Fatalf("Expected ErrRefreshTokenRevoked, got %v", err)
+		}
+	})
+
+	t.Run("Reuse wins over expiry", func(t *testing.T) {
+		err := CheckRefreshToken(time.Now().Add(-time.Hour), true, true)
+		if !errors.Is(err, ErrRefreshTokenReused) {
+			t.Fatalf("Expected ErrRefreshTokenReused, got %v", err)
+		}
+	})
+}
+
+func TestMakeRefreshToken(t *testing.T) {
+	t.Run("Tokens are unique", func(t *testing.T) {
+		first, err := MakeRefreshToken()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		second, err := MakeRefreshToken()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(first) != 64 {
+			t.Errorf("Expected a 64 character token, got %d", len(first))
+		}
+		if first == second {
+			t.Errorf("Two refresh tokens were identical")
+		}
+	})
+}
+
+func TestGetAPIKey(t *testing.T) {
+	t.Run("Valid API key", func(t *testing.T) {
+		headers := make(http.Header)
+		headers.Set("Authorization", "ApiKey f271c81ff7084ee5b99a5091b42d486e")
+
+		key, err := GetAPIKey(headers)
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+		if key != "f271c81ff7084ee5b99a5091b42d486e" {
+			t.Errorf("Expected key to be 'f271c81ff7084ee5b99a5091b42d486e', got %s", key)
+		}
+	})
+
+	t.Run("Missing header", func(t *testing.T) {
+		_, err := GetAPIKey(make(http.Header))
+		if err == nil {
+			t.Errorf("Expected an error for a missing header")
+		}
+	})
+
+	t.Run("Bearer token instead of API key", func(t *testing.T) {
+		headers := make(http.Header)
+		headers.Set("Authorization", "Bearer dummy_token_string")
+
+		_, err := GetAPIKey(headers)
+		if err == nil {
+			t.Errorf("Expected an error for a bearer token")
+		}
+	})
+}
+
+func TestEmailToken(t *testing.T) {
+	userID, tokenID := uuid.New(), uuid.New()
+	token, err := MakeEmailToken(userID, tokenID, tokenSecret, time.Hour)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	t.Run("Round trip", func(t *testing.T) {
+		gotUser, gotToken, err := ValidateEmailToken(token, tokenSecret)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if gotUser != userID || gotToken != tokenID {
+			t.Errorf("Expected %s and %s, got %s and %s", userID, tokenID, gotUser, gotToken)
+		}
+	})
+
+	t.Run("Wrong secret", func(t *testing.T) {
+		if _, _, err := ValidateEmailToken(token, "other-secret"); err == nil {
+			t.Errorf("Expected an error for the wrong secret")
+		}
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		expired, _ := MakeEmailToken(userID, tokenID, tokenSecret, -time.Minute)
+		if _, _, err := ValidateEmailToken(expired, tokenSecret); err == nil {
+			t.Errorf("Expected an error for an expired token")
+		}
+	})
+
+	t.Run("Not an access token", func(t *testing.T) {
+		if _, err := ValidateJWT(token, tokenSecret); err == nil {
+			t.Errorf("Expected an email token to be rejected as an access token")
+		}
+	})
+
+	t.Run("Access token is not an email token", func(t *testing.T) {
+		access, _ := MakeJWT(userID, tokenSecret, time.Hour)
+		if _, _, err := ValidateEmailToken(access, tokenSecret); err == nil {
+			t.Errorf("Expected an access token to be rejected as an email token")
+		}
+	})
+}
+
+func TestHashToken(t *testing.T) {
+	// echo -n abc | sha256sum
+	want := "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+	if got := HashToken("abc"); got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+	if HashToken("abc") == HashToken("abd") {
+		t.Errorf("Different tokens hashed to the same value")
+	}
+}
@@ -0,0 +1,193 @@
+package auth
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"hash"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Test vectors from RFC 6238, appendix B.
+func TestHOTPRFC6238(t *testing.T) {
+	keys := []struct {
+		name string
+		key  string
+		hash func() hash.Hash
+	}{
+		{"SHA1", "12345678901234567890", sha1.New},
+		{"SHA256", "12345678901234567890123456789012", sha256.New},
+		{"SHA512", "1234567890123456789012345678901234567890123456789012345678901234", sha512.New},
+	}
+	vectors := []struct {
+		unix  int64
+		codes [3]string
+	}{
+		{59, [3]string{"94287082", "46119246", "90693936"}},
+		{1111111109, [3]string{"07081804", "68084774", "25091201"}},
+		{1111111111, [3]string{"14050471", "67062674", "99943326"}},
+		{1234567890, [3]string{"89005924", "91819424", "93441116"}},
+		{2000000000, [3]string{"69279037", "90698825", "38618901"}},
+		{20000000000, [3]string{"65353130", "77737706", "47863826"}},
+	}
+	for _, v := range vectors {
+		step := uint64(totpStep(time.Unix(v.unix, 0)))
+		for i, k := range keys {
+			got := hotp([]byte(k.key), step, 8, k.hash)
+			if got != v.codes[i] {
+				t.Errorf("%s at %d: expected %s, got %s", k.name, v.unix, v.codes[i], got)
+			}
+		}
+	}
+}
+
+func TestTOTPCode(t *testing.T) {
+	// The RFC 6238 SHA1 key, base32 encoded.
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	code, err := TOTPCode(secret, time.Unix(1111111109, 0))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	// The six-digit code is the tail of the eight-digit vector.
+	if code != "081804" {
+		t.Errorf("Expected 081804, got %s", code)
+	}
+	lower, _ := TOTPCode(strings.ToLower(secret), time.Unix(1111111109, 0))
+	if lower != code {
+		t.Errorf("Expected a lowercase secret to give %s, got %s", code, lower)
+	}
+	if _, err := TOTPCode("not base32!", time.Now()); !errors.Is(err, ErrInvalidTOTPSecret) {
+		t.Errorf("Expected ErrInvalidTOTPSecret, got %v", err)
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	now := time.Unix(1700000000, 0)
+	tests := []struct {
+		name   string
+		at     time.Time
+		offset int64
+	}{
+		{"Current period", now, 0},
+		{"Previous period", now.Add(-totpPeriod * time.Second), -1},
+		{"Next period", now.Add(totpPeriod * time.Second), 1},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			code, _ := TOTPCode(secret, tc.at)
+			step, err := ValidateTOTP(secret, code, now)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if want := totpStep(now) + tc.offset; step != want {
+				t.Errorf("Expected step %d, got %d", want, step)
+			}
+		})
+	}
+
+	t.Run("Too old", func(t *testing.T) {
+		code, _ := TOTPCode(secret, now.Add(-2*totpPeriod*time.Second))
+		if _, err := ValidateTOTP(secret, code, now); !errors.Is(err, ErrInvalidTOTPCode) {
+			t.Errorf("Expected ErrInvalidTOTPCode, got %v", err)
+		}
+	})
+
+	t.Run("Wrong length", func(t *testing.T) {
+		if _, err := ValidateTOTP(secret, "12345", now); !errors.Is(err, ErrInvalidTOTPCode) {
+			t.Errorf("Expected ErrInvalidTOTPCode, got %v", err)
+		}
+	})
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri := TOTPURI("JBSWY3DPEHPK3PXP", "Chirpy", "alice@example.com")
+	u, err := url.Parse(uri)
+	if err != nil {
+		t.Fatalf("Expected a valid URI, got %v", err)
+	}
+	if u.Scheme != "otpauth" || u.Host != "totp" {
+		t.Errorf("Expected otpauth://totp, got %s://%s", u.Scheme, u.Host)
+	}
+	if u.Path != "/Chirpy:alice@example.com" {
+		t.Errorf("Expected label Chirpy:alice@example.com, got %s", u.Path)
+	}
+	query := u.Query()
+	if query.Get("secret") != "JBSWY3DPEHPK3PXP" || query.Get("issuer") != "Chirpy" {
+		t.Errorf("Unexpected query %s", u.RawQuery)
+	}
+	if query.Get("digits") != "6" || query.Get("period") != "30" {
+		t.Errorf("Unexpected query %s", u.RawQuery)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := MakeRecoveryCodes(10)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if len(code) != 11 || code[5] != '-' {
+			t.Errorf("Expected a code like xxxxx-xxxxx, got %q", code)
+		}
+		if seen[code] {
+			t.Errorf("Duplicate code %q", code)
+		}
+		seen[code] = true
+	}
+	typed := strings.ToUpper(strings.ReplaceAll(codes[0], "-", " "))
+	if HashRecoveryCode(typed) != HashRecoveryCode(codes[0]) {
+		t.Errorf("Expected %q to match %q", typed, codes[0])
+	}
+	if HashRecoveryCode(codes[0]) == HashRecoveryCode(codes[1]) {
+		t.Errorf("Different codes hashed to the same value")
+	}
+}
+
+func TestMFAToken(t *testing.T) {
+	userID := uuid.New()
+	token, err := MakeMFAToken(userID, tokenSecret, time.Minute)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	t.Run("Round trip", func(t *testing.T) {
+		got, err := ValidateMFAToken(token, tokenSecret)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if got != userID {
+			t.Errorf("Expected %s, got %s", userID, got)
+		}
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		expired, _ := MakeMFAToken(userID, tokenSecret, -time.Minute)
+		if _, err := ValidateMFAToken(expired, tokenSecret); err == nil {
+			t.Errorf("Expected an error for an expired token")
+		}
+	})
+
+	t.Run("Not an access token", func(t *testing.T) {
+		if _, err := ValidateJWT(token, tokenSecret); err == nil {
+			t.Errorf("Expected a challenge token to be rejected as an access token")
+		}
+	})
+
+	t.Run("Access token is not a challenge", func(t *testing.T) {
+		access, _ := MakeJWT(userID, tokenSecret, time.Hour)
+		if _, err := ValidateMFAToken(access, tokenSecret); err == nil {
+			t.Errorf("Expected an access token to be rejected as a challenge")
+		}
+	})
+}
@@ -11,27 +11,113 @@ import (
 	"github.com/google/uuid"
 )
 
-type Chirp struct {
-	ID        uuid.UUID `json:"id"`
+type BannedWord struct {
+	Word      string    `json:"word"`
 	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Body      string    `json:"body"`
+}
+
+type Chirp struct {
+	ID           uuid.UUID     `json:"id"`
+	CreatedAt    time.Time     `json:"created_at"`
+	UpdatedAt    time.Time     `json:"updated_at"`
+	Body         string        `json:"body"`
+	UserID       uuid.UUID     `json:"user_id"`
+	InReplyTo    uuid.NullUUID `json:"in_reply_to"`
+	RepostOf     uuid.NullUUID `json:"repost_of"`
+	IsRepost     bool          `json:"is_repost"`
+	SearchVector interface{}   `json:"-"`
+}
+
+type ChirpHashtag struct {
+	ChirpID uuid.UUID `json:"chirp_id"`
+	Tag     string    `json:"tag"`
+}
+
+type ChirpLike struct {
 	UserID    uuid.UUID `json:"user_id"`
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
-type RefreshToken struct {
-	Token     string       `json:"token"`
+type ChirpMedium struct {
+	ID          uuid.UUID `json:"id"`
+	ChirpID     uuid.UUID `json:"chirp_id"`
+	Position    int32     `json:"position"`
+	StorageKey  string    `json:"storage_key"`
+	ContentType string    `json:"content_type"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type ChirpMention struct {
+	ChirpID uuid.UUID `json:"chirp_id"`
+	UserID  uuid.UUID `json:"user_id"`
+}
+
+type EmailVerification struct {
+	ID        uuid.UUID    `json:"id"`
+	UserID    uuid.UUID    `json:"user_id"`
+	Email     string       `json:"email"`
 	CreatedAt time.Time    `json:"created_at"`
-	UpdatedAt time.Time    `json:"updated_at"`
+	ExpiresAt time.Time    `json:"expires_at"`
+	UsedAt    sql.NullTime `json:"used_at"`
+}
+
+type FlaggedChirp struct {
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	CreatedAt time.Time `json:"created_at"`
+	Words     string    `json:"words"`
+}
+
+type Follow struct {
+	FollowerID uuid.UUID `json:"follower_id"`
+	FolloweeID uuid.UUID `json:"followee_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type PasswordReset struct {
+	TokenHash string       `json:"token_hash"`
 	UserID    uuid.UUID    `json:"user_id"`
+	CreatedAt time.Time    `json:"created_at"`
 	ExpiresAt time.Time    `json:"expires_at"`
-	RevokedAt sql.NullTime `json:"revoked_at"`
+	UsedAt    sql.NullTime `json:"used_at"`
+}
+
+type RecoveryCode struct {
+	UserID    uuid.UUID    `json:"user_id"`
+	CodeHash  string       `json:"code_hash"`
+	CreatedAt time.Time    `json:"created_at"`
+	UsedAt    sql.NullTime `json:"used_at"`
+}
+
+type RefreshToken struct {
+	Token      string         `json:"token"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	UserID     uuid.UUID      `json:"user_id"`
+	ExpiresAt  time.Time      `json:"expires_at"`
+	RevokedAt  sql.NullTime   `json:"revoked_at"`
+	Family     uuid.UUID      `json:"family"`
+	ReplacedBy sql.NullString `json:"replaced_by"`
 }
 
 type User struct {
-	ID             uuid.UUID `json:"id"`
-	CreatedAt      time.Time `json:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at"`
-	Email          string    `json:"email"`
-	HashedPassword string    `json:"hashed_password"`
+	ID                uuid.UUID      `json:"id"`
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+	Email             string         `json:"email"`
+	HashedPassword    string         `json:"hashed_password"`
+	IsChirpyRed       bool           `json:"is_chirpy_red"`
+	Handle            sql.NullString `json:"handle"`
+	DisplayName       string         `json:"display_name"`
+	Bio               string         `json:"bio"`
+	AvatarUrl48       string         `json:"avatar_url_48"`
+	AvatarUrl128      string         `json:"avatar_url_128"`
+	AvatarUrl400      string         `json:"avatar_url_400"`
+	FailedLogins      int32          `json:"failed_logins"`
+	LastFailedLoginAt sql.NullTime   `json:"last_failed_login_at"`
+	LockedUntil       sql.NullTime   `json:"locked_until"`
+	EmailVerified     bool           `json:"email_verified"`
+	TotpSecret        string         `json:"totp_secret"`
+	TotpEnabled       bool           `json:"totp_enabled"`
+	TotpLastStep      int64          `json:"totp_last_step"`
 }
@@ -14,9 +14,9 @@ import (
 )
 
 const createRefreshToken = `-- name: CreateRefreshToken :one
-INSERT INTO refresh_tokens(token, created_at, updated_at, user_id, expires_at)
-VALUES ($1, $2, $3, $4, $5)
-  RETURNING token, created_at, updated_at, user_id, expires_at, revoked_at
+INSERT INTO refresh_tokens(token, created_at, updated_at, user_id, expires_at, family)
+VALUES ($1, $2, $3, $4, $5, $6)
+  RETURNING token, created_at, updated_at, user_id, expires_at, revoked_at, family, replaced_by
 `
 
 type CreateRefreshTokenParams struct {
@@ -25,6 +25,7 @@ type CreateRefreshTokenParams struct {
 	UpdatedAt time.Time `json:"updated_at"`
 	UserID    uuid.UUID `json:"user_id"`
 	ExpiresAt time.Time `json:"expires_at"`
+	Family    uuid.UUID `json:"family"`
 }
 
 func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshTokenParams) (RefreshToken, error) {
@@ -34,6 +35,7 @@ func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshToken
 		arg.UpdatedAt,
 		arg.UserID,
 		arg.ExpiresAt,
+		arg.Family,
 	)
 	var i RefreshToken
 	err := row.Scan(
@@ -43,6 +45,29 @@ func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshToken
 		&i.UserID,
 		&i.ExpiresAt,
 		&i.RevokedAt,
+		&i.Family,
+		&i.ReplacedBy,
+	)
+	return i, err
+}
+
+const getRefreshToken = `-- name: GetRefreshToken :one
+SELECT token, created_at, updated_at, user_id, expires_at, revoked_at, family, replaced_by FROM refresh_tokens
+WHERE token = $1
+`
+
+func (q *Queries) GetRefreshToken(ctx context.Context, token string) (RefreshToken, error) {
+	row := q.db.QueryRowContext(ctx, getRefreshToken, token)
+	var i RefreshToken
+	err := row.Scan(
+		&i.Token,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+		&i.Family,
+		&i.ReplacedBy,
 	)
 	return i, err
 }
@@ -64,12 +89,11 @@ func (q *Queries) GetTokenByUserID(ctx context.Context, id uuid.UUID) (string, e
 }
 
 const getUserFromRefreshToken = `-- name: GetUserFromRefreshToken :one
-SELECT id, created_at, updated_at, email, hashed_password FROM users
-WHERE users.id = (
-  SELECT user_id FROM refresh_tokens
-  INNER JOIN users on user_id = users.id
-  WHERE refresh_tokens.token = $1
-)
+SELECT users.id, users.created_at, users.updated_at, users.email, users.hashed_password, users.is_chirpy_red, users.handle, users.display_name, users.bio, users.avatar_url_48, users.avatar_url_128, users.avatar_url_400, users.failed_logins, users.last_failed_login_at, users.locked_until, users.email_verified, users.totp_secret, users.totp_enabled, users.totp_last_step FROM users
+INNER JOIN refresh_tokens on refresh_tokens.user_id = users.id
+WHERE refresh_tokens.token = $1
+  AND refresh_tokens.revoked_at IS NULL
+  AND refresh_tokens.expires_at > NOW()
 `
 
 func (q *Queries) GetUserFromRefreshToken(ctx context.Context, token string) (User, error) {
@@ -81,6 +105,20 @@ func (q *Queries) GetUserFromRefreshToken(ctx context.Context, token string) (Us
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl48,
+		&i.AvatarUrl128,
+		&i.AvatarUrl400,
+		&i.FailedLogins,
+		&i.LastFailedLoginAt,
+		&i.LockedUntil,
+		&i.EmailVerified,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.TotpLastStep,
 	)
 	return i, err
 }
@@ -101,3 +139,63 @@ func (q *Queries) RevokeToken(ctx context.Context, arg RevokeTokenParams) error
 	_, err := q.db.ExecContext(ctx, revokeToken, arg.UpdatedAt, arg.RevokedAt, arg.Token)
 	return err
 }
+
+const revokeTokenFamily = `-- name: RevokeTokenFamily :exec
+UPDATE refresh_tokens
+SET updated_at = $1, revoked_at = $2
+WHERE family = $3 AND revoked_at IS NULL
+`
+
+type RevokeTokenFamilyParams struct {
+	UpdatedAt time.Time    `json:"updated_at"`
+	RevokedAt sql.NullTime `json:"revoked_at"`
+	Family    uuid.UUID    `json:"family"`
+}
+
+func (q *Queries) RevokeTokenFamily(ctx context.Context, arg RevokeTokenFamilyParams) error {
+	_, err := q.db.ExecContext(ctx, revokeTokenFamily, arg.UpdatedAt, arg.RevokedAt, arg.Family)
+	return err
+}
+
+const revokeUserTokens = `-- name: RevokeUserTokens :exec
+UPDATE refresh_tokens
+SET updated_at = $1, revoked_at = $2
+WHERE user_id = $3 AND revoked_at IS NULL
+`
+
+type RevokeUserTokensParams struct {
+	UpdatedAt time.Time    `json:"updated_at"`
+	RevokedAt sql.NullTime `json:"revoked_at"`
+	UserID    uuid.UUID    `json:"user_id"`
+}
+
+func (q *Queries) RevokeUserTokens(ctx context.Context, arg RevokeUserTokensParams) error {
+	_, err := q.db.ExecContext(ctx, revokeUserTokens, arg.UpdatedAt, arg.RevokedAt, arg.UserID)
+	return err
+}
+
+const rotateRefreshToken = `-- name: RotateRefreshToken :execrows
+UPDATE refresh_tokens
+SET updated_at = $1, revoked_at = $2, replaced_by = $3
+WHERE token = $4 AND revoked_at IS NULL
+`
+
+type RotateRefreshTokenParams struct {
+	UpdatedAt  time.Time      `json:"updated_at"`
+	RevokedAt  sql.NullTime   `json:"revoked_at"`
+	ReplacedBy sql.NullString `json:"replaced_by"`
+	Token      string         `json:"token"`
+}
+
+func (q *Queries) RotateRefreshToken(ctx context.Context, arg RotateRefreshTokenParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, rotateRefreshToken,
+		arg.UpdatedAt,
+		arg.RevokedAt,
+		arg.ReplacedBy,
+		arg.Token,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -7,23 +7,26 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createUser = `-- name: CreateUser :one
-INSERT INTO users(id, created_at, updated_at, email, hashed_password)
-VALUES ($1, $2, $3, $4, $5)
-  RETURNING id, created_at, updated_at, email, hashed_password
+INSERT INTO users(id, created_at, updated_at, email, hashed_password, handle)
+VALUES ($1, $2, $3, $4, $5, $6)
+  RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, display_name, bio, avatar_url_48, avatar_url_128, avatar_url_400, failed_logins, last_failed_login_at, locked_until, email_verified, totp_secret, totp_enabled, totp_last_step
 `
 
 type CreateUserParams struct {
-	ID             uuid.UUID `json:"id"`
-	CreatedAt      time.Time `json:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at"`
-	Email          string    `json:"email"`
-	HashedPassword string    `json:"hashed_password"`
+	ID             uuid.UUID      `json:"id"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	Email          string         `json:"email"`
+	HashedPassword string         `json:"hashed_password"`
+	Handle         sql.NullString `json:"handle"`
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
@@ -33,6 +36,7 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		arg.UpdatedAt,
 		arg.Email,
 		arg.HashedPassword,
+		arg.Handle,
 	)
 	var i User
 	err := row.Scan(
@@ -41,6 +45,20 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl48,
+		&i.AvatarUrl128,
+		&i.AvatarUrl400,
+		&i.FailedLogins,
+		&i.LastFailedLoginAt,
+		&i.LockedUntil,
+		&i.EmailVerified,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.TotpLastStep,
 	)
 	return i, err
 }
@@ -54,8 +72,85 @@ func (q *Queries) DeleteUsers(ctx context.Context) error {
 	return err
 }
 
+const disableTOTP = `-- name: DisableTOTP :exec
+UPDATE users
+SET totp_secret = '', totp_enabled = false, totp_last_step = 0, updated_at = $2
+WHERE id = $1
+`
+
+type DisableTOTPParams struct {
+	ID        uuid.UUID `json:"id"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (q *Queries) DisableTOTP(ctx context.Context, arg DisableTOTPParams) error {
+	_, err := q.db.ExecContext(ctx, disableTOTP, arg.ID, arg.UpdatedAt)
+	return err
+}
+
+const enableTOTP = `-- name: EnableTOTP :exec
+UPDATE users
+SET totp_enabled = true, totp_last_step = $2, updated_at = $3
+WHERE id = $1
+`
+
+type EnableTOTPParams struct {
+	ID           uuid.UUID `json:"id"`
+	TotpLastStep int64     `json:"totp_last_step"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+func (q *Queries) EnableTOTP(ctx context.Context, arg EnableTOTPParams) error {
+	_, err := q.db.ExecContext(ctx, enableTOTP, arg.ID, arg.TotpLastStep, arg.UpdatedAt)
+	return err
+}
+
+const getChirpAuthors = `-- name: GetChirpAuthors :many
+SELECT id, handle, display_name, avatar_url_48, avatar_url_128, avatar_url_400 FROM users
+WHERE id = ANY($1::uuid[])
+`
+
+type GetChirpAuthorsRow struct {
+	ID           uuid.UUID      `json:"id"`
+	Handle       sql.NullString `json:"handle"`
+	DisplayName  string         `json:"display_name"`
+	AvatarUrl48  string         `json:"avatar_url_48"`
+	AvatarUrl128 string         `json:"avatar_url_128"`
+	AvatarUrl400 string         `json:"avatar_url_400"`
+}
+
+func (q *Queries) GetChirpAuthors(ctx context.Context, userIds []uuid.UUID) ([]GetChirpAuthorsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpAuthors, pq.Array(userIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetChirpAuthorsRow
+	for rows.Next() {
+		var i GetChirpAuthorsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Handle,
+			&i.DisplayName,
+			&i.AvatarUrl48,
+			&i.AvatarUrl128,
+			&i.AvatarUrl400,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, created_at, updated_at, email, hashed_password FROM users
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, display_name, bio, avatar_url_48, avatar_url_128, avatar_url_400, failed_logins, last_failed_login_at, locked_until, email_verified, totp_secret, totp_enabled, totp_last_step FROM users
 WHERE email = $1
 `
 
@@ -68,6 +163,343 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl48,
+		&i.AvatarUrl128,
+		&i.AvatarUrl400,
+		&i.FailedLogins,
+		&i.LastFailedLoginAt,
+		&i.LockedUntil,
+		&i.EmailVerified,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.TotpLastStep,
+	)
+	return i, err
+}
+
+const getUserByID = `-- name: GetUserByID :one
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, display_name, bio, avatar_url_48, avatar_url_128, avatar_url_400, failed_logins, last_failed_login_at, locked_until, email_verified, totp_secret, totp_enabled, totp_last_step FROM users
+WHERE id = $1
+`
+
+func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByID, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl48,
+		&i.AvatarUrl128,
+		&i.AvatarUrl400,
+		&i.FailedLogins,
+		&i.LastFailedLoginAt,
+		&i.LockedUntil,
+		&i.EmailVerified,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.TotpLastStep,
+	)
+	return i, err
+}
+
+const getUserProfile = `-- name: GetUserProfile :one
+SELECT users.id, users.created_at, users.handle, users.display_name, users.bio,
+  users.avatar_url_48, users.avatar_url_128, users.avatar_url_400,
+  (SELECT COUNT(*) FROM chirps WHERE chirps.user_id = users.id) AS chirp_count,
+  (SELECT COUNT(*) FROM follows WHERE follows.followee_id = users.id) AS follower_count,
+  (SELECT COUNT(*) FROM follows WHERE follows.follower_id = users.id) AS following_count
+FROM users
+WHERE lower(users.handle) = lower($1)
+`
+
+type GetUserProfileRow struct {
+	ID             uuid.UUID      `json:"id"`
+	CreatedAt      time.Time      `json:"created_at"`
+	Handle         sql.NullString `json:"handle"`
+	DisplayName    string         `json:"display_name"`
+	Bio            string         `json:"bio"`
+	AvatarUrl48    string         `json:"avatar_url_48"`
+	AvatarUrl128   string         `json:"avatar_url_128"`
+	AvatarUrl400   string         `json:"avatar_url_400"`
+	ChirpCount     int64          `json:"chirp_count"`
+	FollowerCount  int64          `json:"follower_count"`
+	FollowingCount int64          `json:"following_count"`
+}
+
+func (q *Queries) GetUserProfile(ctx context.Context, handle string) (GetUserProfileRow, error) {
+	row := q.db.QueryRowContext(ctx, getUserProfile, handle)
+	var i GetUserProfileRow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl48,
+		&i.AvatarUrl128,
+		&i.AvatarUrl400,
+		&i.ChirpCount,
+		&i.FollowerCount,
+		&i.FollowingCount,
+	)
+	return i, err
+}
+
+const lockUser = `-- name: LockUser :exec
+UPDATE users
+SET failed_logins = 0, locked_until = $2
+WHERE id = $1
+`
+
+type LockUserParams struct {
+	ID          uuid.UUID    `json:"id"`
+	LockedUntil sql.NullTime `json:"locked_until"`
+}
+
+func (q *Queries) LockUser(ctx context.Context, arg LockUserParams) error {
+	_, err := q.db.ExecContext(ctx, lockUser, arg.ID, arg.LockedUntil)
+	return err
+}
+
+const recordLoginFailure = `-- name: RecordLoginFailure :one
+UPDATE users
+SET failed_logins = CASE
+    WHEN last_failed_login_at > $1::timestamp THEN failed_logins + 1
+    ELSE 1
+  END,
+  last_failed_login_at = $2::timestamp
+WHERE id = $3
+RETURNING failed_logins, last_failed_login_at, locked_until
+`
+
+type RecordLoginFailureParams struct {
+	ResetBefore time.Time `json:"reset_before"`
+	Now         time.Time `json:"now"`
+	ID          uuid.UUID `json:"id"`
+}
+
+type RecordLoginFailureRow struct {
+	FailedLogins      int32        `json:"failed_logins"`
+	LastFailedLoginAt sql.NullTime `json:"last_failed_login_at"`
+	LockedUntil       sql.NullTime `json:"locked_until"`
+}
+
+func (q *Queries) RecordLoginFailure(ctx context.Context, arg RecordLoginFailureParams) (RecordLoginFailureRow, error) {
+	row := q.db.QueryRowContext(ctx, recordLoginFailure, arg.ResetBefore, arg.Now, arg.ID)
+	var i RecordLoginFailureRow
+	err := row.Scan(&i.FailedLogins, &i.LastFailedLoginAt, &i.LockedUntil)
+	return i, err
+}
+
+const resetLoginFailures = `-- name: ResetLoginFailures :exec
+UPDATE users
+SET failed_logins = 0, last_failed_login_at = NULL, locked_until = NULL
+WHERE id = $1
+`
+
+func (q *Queries) ResetLoginFailures(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, resetLoginFailures, id)
+	return err
+}
+
+const setEmailVerified = `-- name: SetEmailVerified :exec
+UPDATE users
+SET email_verified = true, updated_at = $2
+WHERE id = $1
+`
+
+type SetEmailVerifiedParams struct {
+	ID        uuid.UUID `json:"id"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (q *Queries) SetEmailVerified(ctx context.Context, arg SetEmailVerifiedParams) error {
+	_, err := q.db.ExecContext(ctx, setEmailVerified, arg.ID, arg.UpdatedAt)
+	return err
+}
+
+const setTOTPSecret = `-- name: SetTOTPSecret :exec
+UPDATE users
+SET totp_secret = $2, totp_enabled = false, updated_at = $3
+WHERE id = $1
+`
+
+type SetTOTPSecretParams struct {
+	ID         uuid.UUID `json:"id"`
+	TotpSecret string    `json:"totp_secret"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+func (q *Queries) SetTOTPSecret(ctx context.Context, arg SetTOTPSecretParams) error {
+	_, err := q.db.ExecContext(ctx, setTOTPSecret, arg.ID, arg.TotpSecret, arg.UpdatedAt)
+	return err
+}
+
+const setUserPassword = `-- name: SetUserPassword :exec
+UPDATE users
+SET hashed_password = $2, updated_at = $3
+WHERE id = $1
+`
+
+type SetUserPasswordParams struct {
+	ID             uuid.UUID `json:"id"`
+	HashedPassword string    `json:"hashed_password"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+func (q *Queries) SetUserPassword(ctx context.Context, arg SetUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, setUserPassword, arg.ID, arg.HashedPassword, arg.UpdatedAt)
+	return err
+}
+
+const updateUser = `-- name: UpdateUser :one
+UPDATE users
+SET email = $2, hashed_password = $3, updated_at = $4,
+  handle = $5, display_name = $6, bio = $7, email_verified = $8
+WHERE id = $1
+  RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, display_name, bio, avatar_url_48, avatar_url_128, avatar_url_400, failed_logins, last_failed_login_at, locked_until, email_verified, totp_secret, totp_enabled, totp_last_step
+`
+
+type UpdateUserParams struct {
+	ID             uuid.UUID      `json:"id"`
+	Email          string         `json:"email"`
+	HashedPassword string         `json:"hashed_password"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	Handle         sql.NullString `json:"handle"`
+	DisplayName    string         `json:"display_name"`
+	Bio            string         `json:"bio"`
+	EmailVerified  bool           `json:"email_verified"`
+}
+
+func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUser,
+		arg.ID,
+		arg.Email,
+		arg.HashedPassword,
+		arg.UpdatedAt,
+		arg.Handle,
+		arg.DisplayName,
+		arg.Bio,
+		arg.EmailVerified,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl48,
+		&i.AvatarUrl128,
+		&i.AvatarUrl400,
+		&i.FailedLogins,
+		&i.LastFailedLoginAt,
+		&i.LockedUntil,
+		&i.EmailVerified,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.TotpLastStep,
 	)
 	return i, err
 }
+
+const updateUserAvatar = `-- name: UpdateUserAvatar :one
+UPDATE users
+SET avatar_url_48 = $2, avatar_url_128 = $3, avatar_url_400 = $4, updated_at = $5
+WHERE id = $1
+  RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, display_name, bio, avatar_url_48, avatar_url_128, avatar_url_400, failed_logins, last_failed_login_at, locked_until, email_verified, totp_secret, totp_enabled, totp_last_step
+`
+
+type UpdateUserAvatarParams struct {
+	ID           uuid.UUID `json:"id"`
+	AvatarUrl48  string    `json:"avatar_url_48"`
+	AvatarUrl128 string    `json:"avatar_url_128"`
+	AvatarUrl400 string    `json:"avatar_url_400"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+func (q *Queries) UpdateUserAvatar(ctx context.Context, arg UpdateUserAvatarParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserAvatar,
+		arg.ID,
+		arg.AvatarUrl48,
+		arg.AvatarUrl128,
+		arg.AvatarUrl400,
+		arg.UpdatedAt,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl48,
+		&i.AvatarUrl128,
+		&i.AvatarUrl400,
+		&i.FailedLogins,
+		&i.LastFailedLoginAt,
+		&i.LockedUntil,
+		&i.EmailVerified,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.TotpLastStep,
+	)
+	return i, err
+}
+
+const upgradeToChirpyRed = `-- name: UpgradeToChirpyRed :execrows
+UPDATE users
+SET is_chirpy_red = TRUE, updated_at = $2
+WHERE id = $1
+`
+
+type UpgradeToChirpyRedParams struct {
+	ID        uuid.UUID `json:"id"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (q *Queries) UpgradeToChirpyRed(ctx context.Context, arg UpgradeToChirpyRedParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, upgradeToChirpyRed, arg.ID, arg.UpdatedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const useTOTPStep = `-- name: UseTOTPStep :execrows
+UPDATE users
+SET totp_last_step = $2
+WHERE id = $1 AND totp_last_step < $2
+`
+
+type UseTOTPStepParams struct {
+	ID           uuid.UUID `json:"id"`
+	TotpLastStep int64     `json:"totp_last_step"`
+}
+
+func (q *Queries) UseTOTPStep(ctx context.Context, arg UseTOTPStepParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, useTOTPStep, arg.ID, arg.TotpLastStep)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,66 @@
+package handle
+
+import (
+	"errors"
+	"strings"
+)
+
+const (
+	MinLength = 3
+	MaxLength = 20
+)
+
+var (
+	ErrLength   = errors.New("handle must be between 3 and 20 characters")
+	ErrCharset  = errors.New("handle may only contain letters, digits and underscores")
+	ErrStart    = errors.New("handle must start with a letter")
+	ErrReserved = errors.New("handle is reserved")
+)
+
+// reserved holds handles that would be confused with routes, staff or the
+// service itself.  Entries are lower-case.
+var reserved = map[string]bool{
+	"admin":         true,
+	"administrator": true,
+	"api":           true,
+	"app":           true,
+	"chirpy":        true,
+	"help":          true,
+	"me":            true,
+	"moderator":     true,
+	"null":          true,
+	"root":          true,
+	"settings":      true,
+	"staff":         true,
+	"support":       true,
+	"system":        true,
+	"undefined":     true,
+}
+
+// Validate checks a handle against the naming rules.  Handles are compared
+// case-insensitively, so "Admin" is as reserved as "admin".
+func Validate(h string) error {
+	if len(h) < MinLength || len(h) > MaxLength {
+		return ErrLength
+	}
+	for _, r := range h {
+		if !isHandleRune(r) {
+			return ErrCharset
+		}
+	}
+	if !isLetter(rune(h[0])) {
+		return ErrStart
+	}
+	if reserved[strings.ToLower(h)] {
+		return ErrReserved
+	}
+	return nil
+}
+
+func isLetter(r rune) bool {
+	return ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z')
+}
+
+func isHandleRune(r rune) bool {
+	return isLetter(r) || ('0' <= r && r <= '9') || r == '_'
+}
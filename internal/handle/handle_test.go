@@ -0,0 +1,35 @@
+package handle
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		handle string
+		want   error
+	}{
+		{"alice", nil},
+		{"Bob_99", nil},
+		{"abc", nil},
+		{"a_very_long_handle_x", nil},
+		{"ab", ErrLength},
+		{"a_very_long_handle_xy", ErrLength},
+		{"bad-name", ErrCharset},
+		{"café", ErrCharset},
+		{"has space", ErrCharset},
+		{"_alice", ErrStart},
+		{"9lives", ErrStart},
+		{"admin", ErrReserved},
+		{"ADMIN", ErrReserved},
+		{"Support", ErrReserved},
+	}
+	for _, tc := range tests {
+		t.Run(tc.handle, func(t *testing.T) {
+			if err := Validate(tc.handle); !errors.Is(err, tc.want) {
+				t.Errorf("Validate(%q) = %v, want %v", tc.handle, err, tc.want)
+			}
+		})
+	}
+}
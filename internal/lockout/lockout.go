@@ -0,0 +1,150 @@
+// Package lockout decides how long a login must wait after failed
+// password attempts, and when an account is locked outright.
+package lockout
+
+import (
+	"sync"
+	"time"
+)
+
+// Policy describes the backoff and lockout rules.
+type Policy struct {
+	// FreeAttempts failures are allowed before any delay applies.
+	FreeAttempts int
+	// BaseDelay is the wait after the first failure past FreeAttempts.  It
+	// doubles with each further failure, up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// LockAfter failures lock the account for LockFor.  Zero disables
+	// locking.
+	LockAfter int
+	LockFor   time.Duration
+	// ResetAfter is how long without a failure before the count starts
+	// over.
+	ResetAfter time.Duration
+}
+
+// DefaultPolicy allows three free attempts, then waits 1s, 2s, 4s and so
+// on, and locks for 15 minutes after ten failures.
+var DefaultPolicy = Policy{
+	FreeAttempts: 3,
+	BaseDelay:    time.Second,
+	MaxDelay:     5 * time.Minute,
+	LockAfter:    10,
+	LockFor:      15 * time.Minute,
+	ResetAfter:   time.Hour,
+}
+
+// State is the failure history of one account or address.
+type State struct {
+	Failures    int
+	LastFailure time.Time
+	LockedUntil time.Time
+}
+
+// Delay returns the backoff owed after the given number of failures.
+func (p Policy) Delay(failures int) time.Duration {
+	over := failures - p.FreeAttempts
+	if over <= 0 {
+		return 0
+	}
+	delay := p.BaseDelay
+	for i := 1; i < over && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, p.MaxDelay)
+}
+
+// Check returns how long the next attempt must wait, and whether that is
+// because of a lock rather than ordinary backoff.
+func (p Policy) Check(s State, now time.Time) (time.Duration, bool) {
+	if now.Before(s.LockedUntil) {
+		return s.LockedUntil.Sub(now), true
+	}
+	if s.Failures == 0 || now.Sub(s.LastFailure) >= p.ResetAfter {
+		return 0, false
+	}
+	if wait := s.LastFailure.Add(p.Delay(s.Failures)).Sub(now); wait > 0 {
+		return wait, false
+	}
+	return 0, false
+}
+
+// Fail records a failed attempt at now.
+func (p Policy) Fail(s State, now time.Time) State {
+	if now.Sub(s.LastFailure) >= p.ResetAfter {
+		s.Failures = 0
+	}
+	s.Failures++
+	s.LastFailure = now
+	return p.Lock(s, now)
+}
+
+// Lock locks s if its failure count, which already includes the latest
+// failure at now, has reached LockAfter.  It is separate from Fail for
+// stores that count failures themselves.
+func (p Policy) Lock(s State, now time.Time) State {
+	if p.LockAfter > 0 && s.Failures >= p.LockAfter {
+		s.LockedUntil = now.Add(p.LockFor)
+		// The lock replaces the backoff; the count starts again after it.
+		s.Failures = 0
+	}
+	return s
+}
+
+// sweepInterval is how often a Tracker drops states that have expired.
+const sweepInterval = time.Minute
+
+// Tracker keeps States in memory, for keys that have nowhere else to
+// live such as client addresses.  It is safe for concurrent use.
+type Tracker struct {
+	policy    Policy
+	mu        sync.Mutex
+	states    map[string]State
+	lastSweep time.Time
+}
+
+func NewTracker(policy Policy) *Tracker {
+	return &Tracker{policy: policy, states: make(map[string]State)}
+}
+
+func (t *Tracker) Policy() Policy {
+	return t.policy
+}
+
+// Check is Policy.Check for the state stored under key.
+func (t *Tracker) Check(key string, now time.Time) (time.Duration, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.policy.Check(t.states[key], now)
+}
+
+// Fail records a failure under key.
+func (t *Tracker) Fail(key string, now time.Time) State {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if now.Sub(t.lastSweep) >= sweepInterval {
+		t.sweep(now)
+	}
+	s := t.policy.Fail(t.states[key], now)
+	t.states[key] = s
+	return s
+}
+
+// sweep forgets states that have expired, so the map does not grow
+// without bound.
+func (t *Tracker) sweep(now time.Time) {
+	for k, s := range t.states {
+		if now.Sub(s.LastFailure) >= t.policy.ResetAfter && !now.Before(s.LockedUntil) {
+			delete(t.states, k)
+		}
+	}
+	t.lastSweep = now
+}
+
+// Reset forgets every failure under key.
+func (t *Tracker) Reset(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.states, key)
+}
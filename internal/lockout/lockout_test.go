@@ -0,0 +1,117 @@
+package lockout
+
+import (
+	"testing"
+	"time"
+)
+
+var testPolicy = Policy{
+	FreeAttempts: 2,
+	BaseDelay:    time.Second,
+	MaxDelay:     5 * time.Second,
+	LockAfter:    6,
+	LockFor:      time.Minute,
+	ResetAfter:   time.Hour,
+}
+
+func TestDelay(t *testing.T) {
+	want := []time.Duration{0, 0, 0, time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for failures, w := range want {
+		if got := testPolicy.Delay(failures); got != w {
+			t.Errorf("Delay(%d) = %s, want %s", failures, got, w)
+		}
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var s State
+	for i := 0; i < 2; i++ {
+		s = testPolicy.Fail(s, now)
+		if wait, locked := testPolicy.Check(s, now); wait != 0 || locked {
+			t.Fatalf("Check() after %d failures = %s, %v, want no wait", s.Failures, wait, locked)
+		}
+	}
+	s = testPolicy.Fail(s, now)
+	if wait, locked := testPolicy.Check(s, now); wait != time.Second || locked {
+		t.Errorf("Check() after 3 failures = %s, %v, want 1s", wait, locked)
+	}
+	if wait, _ := testPolicy.Check(s, now.Add(time.Second)); wait != 0 {
+		t.Errorf("Check() once the backoff passed = %s, want 0", wait)
+	}
+
+	for s.Failures < 5 {
+		s = testPolicy.Fail(s, now)
+	}
+	s = testPolicy.Fail(s, now)
+	wait, locked := testPolicy.Check(s, now.Add(10*time.Second))
+	if !locked || wait != 50*time.Second {
+		t.Errorf("Check() after 6 failures = %s, %v, want locked for 50s", wait, locked)
+	}
+	if wait, locked := testPolicy.Check(s, now.Add(time.Minute)); wait != 0 || locked {
+		t.Errorf("Check() once the lock expired = %s, %v, want no wait", wait, locked)
+	}
+}
+
+func TestPolicyReset(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := State{Failures: 5, LastFailure: now}
+	later := now.Add(time.Hour)
+	if wait, _ := testPolicy.Check(s, later); wait != 0 {
+		t.Errorf("Check() after ResetAfter = %s, want 0", wait)
+	}
+	if s = testPolicy.Fail(s, later); s.Failures != 1 {
+		t.Errorf("Fail() after ResetAfter left %d failures, want 1", s.Failures)
+	}
+}
+
+func TestPolicyLock(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := State{Failures: 5, LastFailure: now}
+	if got := testPolicy.Lock(s, now); got != s {
+		t.Errorf("Lock() below LockAfter = %+v, want %+v", got, s)
+	}
+	// A count that raced past LockAfter still locks.
+	s.Failures = 8
+	got := testPolicy.Lock(s, now)
+	if got.Failures != 0 || !got.LockedUntil.Equal(now.Add(time.Minute)) {
+		t.Errorf("Lock() at 8 failures = %+v, want locked for 1m with the count reset", got)
+	}
+}
+
+func TestTracker(t *testing.T) {
+	tracker := NewTracker(testPolicy)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		tracker.Fail("ip:1", now)
+	}
+	if wait, _ := tracker.Check("ip:1", now); wait != time.Second {
+		t.Errorf("Check() = %s, want 1s", wait)
+	}
+	if wait, _ := tracker.Check("ip:2", now); wait != 0 {
+		t.Errorf("Check() of another key = %s, want 0", wait)
+	}
+	tracker.Reset("ip:1")
+	if wait, _ := tracker.Check("ip:1", now); wait != 0 {
+		t.Errorf("Check() after Reset() = %s, want 0", wait)
+	}
+
+}
+
+func TestTrackerSweep(t *testing.T) {
+	tracker := NewTracker(testPolicy)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tracker.Fail("old", now)
+	// Within sweepInterval of the last sweep nothing is scanned.
+	tracker.Fail("new", now.Add(30*time.Second))
+	if len(tracker.states) != 2 {
+		t.Errorf("%d states after two failures, want 2", len(tracker.states))
+	}
+	tracker.Fail("new", now.Add(2*time.Hour))
+	if _, ok := tracker.states["old"]; ok {
+		t.Error("an expired state was not dropped")
+	}
+	if _, ok := tracker.states["new"]; !ok {
+		t.Error("the state in use was dropped")
+	}
+}
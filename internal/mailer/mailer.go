@@ -0,0 +1,287 @@
+// Package mailer sends transactional email such as verification links.
+// The Dir mailer writes messages to disk so flows can be exercised
+// without a mail server.
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Message is a plain-text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers a message or reports why it could not.  Send gives up
+// once ctx is done.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Config selects and configures a mailer.
+type Config struct {
+	// Backend is "dir" or "smtp".  Empty means "dir".
+	Backend string
+	From    string
+	// Dir is where the dir mailer writes .eml files.  Empty means
+	// DefaultDir.
+	Dir string
+	// SMTPAddr is host:port.  Username may be empty for servers that
+	// accept mail without authentication.
+	SMTPAddr     string
+	SMTPUsername string
+	SMTPPassword string
+}
+
+// ConfigFromEnv reads MAILER, MAIL_FROM, MAIL_DIR and the SMTP_*
+// variables.
+func ConfigFromEnv() Config {
+	return Config{
+		Backend:      os.Getenv("MAILER"),
+		From:         os.Getenv("MAIL_FROM"),
+		Dir:          os.Getenv("MAIL_DIR"),
+		SMTPAddr:     os.Getenv("SMTP_ADDR"),
+		SMTPUsername: os.Getenv("SMTP_USERNAME"),
+		SMTPPassword: os.Getenv("SMTP_PASSWORD"),
+	}
+}
+
+const defaultFrom = "Chirpy <no-reply@chirpy.local>"
+
+// DefaultDir is where the dir mailer writes when no directory is
+// configured.  It is outside the working directory because the server
+// publishes that under /app/, and the messages carry verification and
+// password reset tokens.
+func DefaultDir() string {
+	return filepath.Join(os.TempDir(), "chirpy-mail")
+}
+
+// Open returns the mailer cfg asks for.  On error the Mailer is nil, not a
+// nil *Dir or *SMTP.
+func Open(cfg Config) (Mailer, error) {
+	from := cfg.From
+	if from == "" {
+		from = defaultFrom
+	}
+	switch strings.ToLower(cfg.Backend) {
+	case "", "dir":
+		dir := cfg.Dir
+		if dir == "" {
+			dir = DefaultDir()
+		}
+		m, err := NewDir(dir, from)
+		if err != nil {
+			return nil, err
+		}
+		return m, nil
+	case "smtp":
+		m, err := NewSMTP(cfg.SMTPAddr, cfg.SMTPUsername, cfg.SMTPPassword, from)
+		if err != nil {
+			return nil, err
+		}
+		return m, nil
+	}
+	return nil, fmt.Errorf("unknown mailer %q", cfg.Backend)
+}
+
+// ErrInvalidHeader is returned for addresses or subjects that contain line
+// breaks, which would let them inject headers.
+var ErrInvalidHeader = errors.New("header contains a line break")
+
+// format renders msg as an RFC 5322 message with a quoted-printable body.
+func format(from string, msg Message, date time.Time) ([]byte, error) {
+	for _, value := range []string{from, msg.To, msg.Subject} {
+		if strings.ContainsAny(value, "\r\n") {
+			return nil, ErrInvalidHeader
+		}
+	}
+	var id [12]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return nil, err
+	}
+	domain := "chirpy.local"
+	if addr, err := mail.ParseAddress(from); err == nil {
+		if _, host, found := strings.Cut(addr.Address, "@"); found {
+			domain = host
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: <%s@%s>\r\n", hex.EncodeToString(id[:]), domain)
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+	qp := quotedprintable.NewWriter(&buf)
+	qp.Write([]byte(strings.ReplaceAll(msg.Body, "\n", "\r\n")))
+	if err := qp.Close(); err != nil {
+		return nil, err
+	}
+	buf.WriteString("\r\n")
+	return buf.Bytes(), nil
+}
+
+// Dir writes each message to its own .eml file, which any mail client can
+// open.  Only the server's user can read them, since they hold tokens.
+type Dir struct {
+	dir  string
+	from string
+}
+
+// NewDir creates dir if needed.
+func NewDir(dir, from string) (*Dir, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("creating mail directory: %w", err)
+	}
+	return &Dir{dir: dir, from: from}, nil
+}
+
+// Path returns the directory messages are written to.
+func (d *Dir) Path() string {
+	return d.dir
+}
+
+func (d *Dir) Send(ctx context.Context, msg Message) error {
+	now := time.Now()
+	data, err := format(d.from, msg, now)
+	if err != nil {
+		return err
+	}
+	var suffix [4]byte
+	if _, err := rand.Read(suffix[:]); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.eml", now.UTC().Format("20060102T150405.000000000Z"), hex.EncodeToString(suffix[:]))
+	return os.WriteFile(filepath.Join(d.dir, name), data, 0o600)
+}
+
+// smtpTimeout caps a whole SMTP conversation, so a stalled relay cannot
+// hold up a request even when its context has no deadline.
+const smtpTimeout = 30 * time.Second
+
+// SMTP delivers through a relay using PLAIN authentication.  It upgrades
+// to TLS whenever the server offers STARTTLS, and net/smtp refuses to
+// send credentials in the clear except to localhost.
+type SMTP struct {
+	addr string
+	host string
+	auth smtp.Auth
+	from string
+	// envelopeFrom is the bare address used in MAIL FROM.
+	envelopeFrom string
+	timeout      time.Duration
+}
+
+func NewSMTP(addr, username, password, from string) (*SMTP, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SMTP address %q: %w", addr, err)
+	}
+	sender, err := mail.ParseAddress(from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender %q: %w", from, err)
+	}
+	m := &SMTP{addr: addr, host: host, from: from, envelopeFrom: sender.Address, timeout: smtpTimeout}
+	if username != "" {
+		m.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return m, nil
+}
+
+func (m *SMTP) Send(ctx context.Context, msg Message) error {
+	data, err := format(m.from, msg, time.Now())
+	if err != nil {
+		return err
+	}
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("invalid recipient %q: %w", msg.To, err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+	err = m.send(ctx, to.Address, data)
+	if err == nil {
+		return nil
+	}
+	// Every deadline on the connection comes from ctx, so a timeout means
+	// ctx is done or about to be.
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		<-ctx.Done()
+	}
+	if ctx.Err() != nil {
+		return fmt.Errorf("sending mail: %w", ctx.Err())
+	}
+	return err
+}
+
+// send is smtp.SendMail on a connection that ctx can interrupt.
+func (m *SMTP) send(ctx context.Context, to string, data []byte) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", m.addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	c, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: m.host}); err != nil {
+			return err
+		}
+	}
+	if m.auth != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errors.New("smtp: server doesn't support AUTH")
+		}
+		if err := c.Auth(m.auth); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(m.envelopeFrom); err != nil {
+		return err
+	}
+	if err := c.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
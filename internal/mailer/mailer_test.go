@@ -0,0 +1,250 @@
+package mailer
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testBody = "Welcome to Chirpy!\n\nVisit http://localhost:8080/api/verify?token=abc.def=ghi to confirm."
+
+func readMessage(t *testing.T, data []byte) (*mail.Message, string) {
+	t.Helper()
+	msg, err := mail.ReadMessage(strings.NewReader(string(data)))
+	if err != nil {
+		t.Fatalf("reading message: %v", err)
+	}
+	body, err := io.ReadAll(quotedprintable.NewReader(msg.Body))
+	if err != nil {
+		t.Fatalf("decoding body: %v", err)
+	}
+	return msg, strings.ReplaceAll(string(body), "\r\n", "\n")
+}
+
+func TestDir(t *testing.T) {
+	dir := t.TempDir()
+	m, err := NewDir(dir, defaultFrom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.Send(context.Background(), Message{To: "someone@example.com", Subject: "Confirm your email ✓", Body: testBody})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if len(files) != 1 {
+		t.Fatalf("found %d .eml files, want 1", len(files))
+	}
+	info, err := os.Stat(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0o600 {
+		t.Errorf("message mode = %v, want 0600", mode)
+	}
+	data, _ := os.ReadFile(files[0])
+	msg, body := readMessage(t, data)
+	if got := msg.Header.Get("To"); got != "someone@example.com" {
+		t.Errorf("To = %q", got)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || subject != "Confirm your email ✓" {
+		t.Errorf("Subject = %q, %v", subject, err)
+	}
+	if body != testBody+"\n" {
+		t.Errorf("body = %q, want %q", body, testBody+"\n")
+	}
+}
+
+func TestHeaderInjection(t *testing.T) {
+	m, err := NewDir(t.TempDir(), defaultFrom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, msg := range []Message{
+		{To: "a@example.com\r\nBcc: victim@example.com", Subject: "hi"},
+		{To: "a@example.com", Subject: "hi\nBcc: victim@example.com"},
+	} {
+		if err := m.Send(context.Background(), msg); !errors.Is(err, ErrInvalidHeader) {
+			t.Errorf("Send(%q) error = %v, want %v", msg, err, ErrInvalidHeader)
+		}
+	}
+}
+
+// fakeSMTP accepts one message on a local port and hands back the DATA.
+func fakeSMTP(t *testing.T) (string, <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	received := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { io.WriteString(conn, s+"\r\n") }
+		reply("220 localhost ESMTP")
+		var envelope []string
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"):
+				reply("250-localhost")
+				reply("250 AUTH PLAIN")
+			case strings.HasPrefix(cmd, "AUTH PLAIN"):
+				reply("235 OK")
+			case strings.HasPrefix(cmd, "MAIL FROM"), strings.HasPrefix(cmd, "RCPT TO"):
+				envelope = append(envelope, strings.TrimSpace(line))
+				reply("250 OK")
+			case cmd == "DATA":
+				reply("354 Go ahead")
+				var data strings.Builder
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil || line == ".\r\n" {
+						break
+					}
+					data.WriteString(line)
+				}
+				received <- strings.Join(envelope, "\n") + "\n\n" + data.String()
+				reply("250 OK")
+			case cmd == "QUIT":
+				reply("221 Bye")
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+	return ln.Addr().String(), received
+}
+
+func TestSMTP(t *testing.T) {
+	addr, received := fakeSMTP(t)
+	m, err := NewSMTP(addr, "chirpy", "secret", defaultFrom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.Send(context.Background(), Message{To: "Someone <someone@example.com>", Subject: "Confirm", Body: testBody})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	envelope, data, _ := strings.Cut(<-received, "\n\n")
+	if want := "MAIL FROM:<no-reply@chirpy.local>\nRCPT TO:<someone@example.com>"; !strings.HasPrefix(envelope, want) {
+		t.Errorf("envelope = %q, want prefix %q", envelope, want)
+	}
+	_, body := readMessage(t, []byte(data))
+	if body != testBody+"\n" {
+		t.Errorf("body = %q, want %q", body, testBody+"\n")
+	}
+}
+
+func TestSMTPStalled(t *testing.T) {
+	// This server accepts connections and then never says a word.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conns := make(chan net.Conn, 8)
+	t.Cleanup(func() {
+		ln.Close()
+		close(conns)
+		for conn := range conns {
+			conn.Close()
+		}
+	})
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conns <- conn
+		}
+	}()
+	m, err := NewSMTP(ln.Addr().String(), "", "", defaultFrom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := Message{To: "someone@example.com", Subject: "Confirm", Body: testBody}
+
+	t.Run("Context deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		err := m.Send(ctx, msg)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Send() error = %v, want %v", err, context.DeadlineExceeded)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("Send() took %s after its deadline", elapsed)
+		}
+	})
+
+	t.Run("Cancelled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(50*time.Millisecond, cancel)
+		if err := m.Send(ctx, msg); !errors.Is(err, context.Canceled) {
+			t.Errorf("Send() error = %v, want %v", err, context.Canceled)
+		}
+	})
+
+	t.Run("No deadline", func(t *testing.T) {
+		m.timeout = 50 * time.Millisecond
+		if err := m.Send(context.Background(), msg); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Send() error = %v, want %v", err, context.DeadlineExceeded)
+		}
+	})
+}
+
+func TestOpen(t *testing.T) {
+	m, err := Open(Config{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if _, ok := m.(*Dir); !ok {
+		t.Errorf("Open() with no backend = %T, want *Dir", m)
+	}
+	// The default must stay out of the working directory, which the
+	// server publishes.
+	t.Setenv("TMPDIR", t.TempDir())
+	m, err = Open(Config{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if got := m.(*Dir).Path(); got != DefaultDir() {
+		t.Errorf("Open() with no directory writes to %q, want %q", got, DefaultDir())
+	}
+	if wd, _ := os.Getwd(); strings.HasPrefix(DefaultDir(), wd) {
+		t.Errorf("DefaultDir() = %q is inside the working directory", DefaultDir())
+	}
+	m, err = Open(Config{Backend: "smtp", SMTPAddr: "no-port"})
+	if err == nil {
+		t.Error("Open() of smtp with a bad address succeeded")
+	}
+	if m != nil {
+		t.Errorf("Open() of smtp with a bad address = %#v, want nil", m)
+	}
+	if _, err := Open(Config{Backend: "pigeon"}); err == nil {
+		t.Error("Open() of an unknown backend succeeded")
+	}
+}
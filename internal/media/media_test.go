@@ -0,0 +1,176 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func testImage() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	img.Set(1, 1, color.RGBA{R: 255, A: 255})
+	return img
+}
+
+func encodeJPEG(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, testImage(), nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func encodePNG(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, testImage()); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// withJPEGExif inserts an APP1 EXIF segment straight after the SOI marker.
+func withJPEGExif(data []byte) []byte {
+	payload := []byte("Exif\x00\x00GPS-SECRET")
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	segment = append(segment, payload...)
+	out := append([]byte{}, data[:2]...)
+	out = append(out, segment...)
+	return append(out, data[2:]...)
+}
+
+// withPNGChunk inserts a chunk straight after the IHDR chunk.
+func withPNGChunk(data []byte, kind string, payload []byte) []byte {
+	ihdrEnd := len(pngSignature) + 12 + 13
+	chunk := make([]byte, 4, 12+len(payload))
+	binary.BigEndian.PutUint32(chunk, uint32(len(payload)))
+	chunk = append(chunk, kind...)
+	chunk = append(chunk, payload...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+	out := append([]byte{}, data[:ihdrEnd]...)
+	out = append(out, chunk...)
+	return append(out, data[ihdrEnd:]...)
+}
+
+func webpChunk(fourCC string, payload []byte) []byte {
+	chunk := []byte(fourCC)
+	chunk = binary.LittleEndian.AppendUint32(chunk, uint32(len(payload)))
+	chunk = append(chunk, payload...)
+	if len(payload)%2 == 1 {
+		chunk = append(chunk, 0)
+	}
+	return chunk
+}
+
+func webpFile(chunks ...[]byte) []byte {
+	body := []byte("WEBP")
+	for _, c := range chunks {
+		body = append(body, c...)
+	}
+	out := []byte("RIFF")
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(body)))
+	return append(out, body...)
+}
+
+func TestSniff(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		want    string
+		wantErr error
+	}{
+		{"JPEG", encodeJPEG(t), "image/jpeg", nil},
+		{"PNG", encodePNG(t), "image/png", nil},
+		{"GIF", []byte("GIF89a\x01\x00\x01\x00"), "image/gif", nil},
+		{"WebP", webpFile(webpChunk("VP8 ", []byte{0, 0})), "image/webp", nil},
+		{"Plain text", []byte("hello, world"), "", ErrUnsupportedType},
+		{"HTML", []byte("<html><script>alert(1)</script></html>"), "", ErrUnsupportedType},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Sniff(tc.data)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("Sniff() error = %v, want %v", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("Sniff() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestStripJPEG(t *testing.T) {
+	data := withJPEGExif(encodeJPEG(t))
+	stripped, err := StripMetadata(data, "image/jpeg")
+	if err != nil {
+		t.Fatalf("StripMetadata() error = %v", err)
+	}
+	if bytes.Contains(stripped, []byte("GPS-SECRET")) {
+		t.Error("EXIF segment survived stripping")
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(stripped)); err != nil {
+		t.Errorf("stripped JPEG no longer decodes: %v", err)
+	}
+}
+
+func TestStripPNG(t *testing.T) {
+	data := encodePNG(t)
+	data = withPNGChunk(data, "eXIf", []byte("GPS-SECRET"))
+	data = withPNGChunk(data, "tEXt", []byte("Author\x00someone"))
+	stripped, err := StripMetadata(data, "image/png")
+	if err != nil {
+		t.Fatalf("StripMetadata() error = %v", err)
+	}
+	if bytes.Contains(stripped, []byte("GPS-SECRET")) || bytes.Contains(stripped, []byte("someone")) {
+		t.Error("metadata chunk survived stripping")
+	}
+	if _, err := png.Decode(bytes.NewReader(stripped)); err != nil {
+		t.Errorf("stripped PNG no longer decodes: %v", err)
+	}
+}
+
+func TestStripWebP(t *testing.T) {
+	vp8x := make([]byte, 10)
+	vp8x[0] = webpFlagEXIF | webpFlagXMP
+	data := webpFile(
+		webpChunk("VP8X", vp8x),
+		webpChunk("VP8 ", []byte{1, 2, 3, 4}),
+		webpChunk("EXIF", []byte("GPS-SECRET")),
+		webpChunk("XMP ", []byte("<x/>")),
+	)
+	stripped, err := StripMetadata(data, "image/webp")
+	if err != nil {
+		t.Fatalf("StripMetadata() error = %v", err)
+	}
+	want := webpFile(webpChunk("VP8X", make([]byte, 10)), webpChunk("VP8 ", []byte{1, 2, 3, 4}))
+	if !bytes.Equal(stripped, want) {
+		t.Errorf("StripMetadata() = %q, want %q", stripped, want)
+	}
+}
+
+func TestStripMalformed(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        []byte
+		contentType string
+	}{
+		{"Truncated JPEG segment", []byte{0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x40}, "image/jpeg"},
+		{"Truncated PNG chunk", append(append([]byte{}, pngSignature...), 0, 0, 0, 99, 'I', 'H', 'D', 'R'), "image/png"},
+		{"WebP chunk past the end", webpFile([]byte("VP8 \xff\x00\x00\x00")), "image/webp"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := StripMetadata(tc.data, tc.contentType); !errors.Is(err, ErrMalformed) {
+				t.Errorf("StripMetadata() error = %v, want %v", err, ErrMalformed)
+			}
+		})
+	}
+}
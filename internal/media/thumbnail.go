@@ -0,0 +1,77 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	_ "image/jpeg"
+	_ "image/png"
+)
+
+// MaxPixels bounds the images Decode accepts, so a small file that
+// claims enormous dimensions cannot exhaust memory.
+const MaxPixels = 40_000_000
+
+// ErrTooManyPixels is returned for images larger than MaxPixels.
+var ErrTooManyPixels = errors.New("image dimensions are too large")
+
+// Decode reads a JPEG or PNG image after checking its dimensions.
+func Decode(data []byte) (image.Image, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	if config.Width <= 0 || config.Height <= 0 {
+		return nil, ErrMalformed
+	}
+	if int64(config.Width)*int64(config.Height) > MaxPixels {
+		return nil, ErrTooManyPixels
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	return img, nil
+}
+
+// Thumbnail crops the centre square out of src and scales it to size by
+// size pixels.  Each output pixel is the area-weighted average of the
+// source pixels it covers, which keeps downscaled edges smooth.
+func Thumbnail(src image.Image, size int) *image.RGBA {
+	b := src.Bounds()
+	side := min(b.Dx(), b.Dy())
+	crop := image.Rect(0, 0, side, side)
+	offset := image.Pt(b.Min.X+(b.Dx()-side)/2, b.Min.Y+(b.Dy()-side)/2)
+	// Work in premultiplied RGBA so transparent pixels do not bleed their
+	// colour into the average.
+	square := image.NewRGBA(crop)
+	draw.Draw(square, crop, src, offset, draw.Src)
+
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	scale := float64(side) / float64(size)
+	for dy := 0; dy < size; dy++ {
+		y0, y1 := float64(dy)*scale, float64(dy+1)*scale
+		for dx := 0; dx < size; dx++ {
+			x0, x1 := float64(dx)*scale, float64(dx+1)*scale
+			var sum [4]float64
+			var total float64
+			for sy := int(y0); sy < side && float64(sy) < y1; sy++ {
+				wy := min(y1, float64(sy+1)) - max(y0, float64(sy))
+				for sx := int(x0); sx < side && float64(sx) < x1; sx++ {
+					w := wy * (min(x1, float64(sx+1)) - max(x0, float64(sx)))
+					i := square.PixOffset(sx, sy)
+					for c := 0; c < 4; c++ {
+						sum[c] += w * float64(square.Pix[i+c])
+					}
+					total += w
+				}
+			}
+			i := dst.PixOffset(dx, dy)
+			for c := 0; c < 4; c++ {
+				dst.Pix[i+c] = uint8(sum[c]/total + 0.5)
+			}
+		}
+	}
+	return dst
+}
@@ -0,0 +1,82 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func TestThumbnail(t *testing.T) {
+	// A 300x200 image: the left half is red, the right half blue.  The
+	// centre crop is x 50-250, so the thumbnail should split down the
+	// middle.
+	src := image.NewRGBA(image.Rect(0, 0, 300, 200))
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 300; x++ {
+			c := color.RGBA{R: 255, A: 255}
+			if x >= 150 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			src.Set(x, y, c)
+		}
+	}
+	for _, size := range []int{48, 128, 400} {
+		thumb := Thumbnail(src, size)
+		if got := thumb.Bounds(); got != image.Rect(0, 0, size, size) {
+			t.Fatalf("Thumbnail(%d) bounds = %v", size, got)
+		}
+		if got := thumb.RGBAAt(0, size/2); got != (color.RGBA{R: 255, A: 255}) {
+			t.Errorf("Thumbnail(%d) left edge = %v, want red", size, got)
+		}
+		if got := thumb.RGBAAt(size-1, size/2); got != (color.RGBA{B: 255, A: 255}) {
+			t.Errorf("Thumbnail(%d) right edge = %v, want blue", size, got)
+		}
+	}
+}
+
+func TestThumbnailAverages(t *testing.T) {
+	// Alternating black and white columns average to mid grey.
+	src := image.NewGray(image.Rect(0, 0, 4, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			if x%2 == 0 {
+				src.SetGray(x, y, color.Gray{Y: 255})
+			}
+		}
+	}
+	thumb := Thumbnail(src, 2)
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 2; x++ {
+			if got := thumb.RGBAAt(x, y); got != (color.RGBA{128, 128, 128, 255}) {
+				t.Errorf("pixel (%d, %d) = %v, want mid grey", x, y, got)
+			}
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	if _, err := Decode(encodePNG(t)); err != nil {
+		t.Errorf("Decode(PNG) error = %v", err)
+	}
+	if _, err := Decode(encodeJPEG(t)); err != nil {
+		t.Errorf("Decode(JPEG) error = %v", err)
+	}
+	if _, err := Decode([]byte("GIF89a")); !errors.Is(err, ErrMalformed) {
+		t.Errorf("Decode(GIF) error = %v, want %v", err, ErrMalformed)
+	}
+
+	// Claim 10000x10000 in the header; DecodeConfig never reads the pixels.
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewGray(image.Rect(0, 0, 10000, 1)))
+	data := buf.Bytes()
+	binary.BigEndian.PutUint32(data[20:24], 10000)
+	binary.BigEndian.PutUint32(data[29:33], crc32.ChecksumIEEE(data[12:29]))
+	if _, err := Decode(data); !errors.Is(err, ErrTooManyPixels) {
+		t.Errorf("Decode(huge) error = %v, want %v", err, ErrTooManyPixels)
+	}
+}
@@ -0,0 +1,175 @@
+package profanity
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Mode decides what happens to a chirp that contains a banned word.
+type Mode string
+
+const (
+	// ModeMask replaces each banned word with asterisks.
+	ModeMask Mode = "mask"
+	// ModeReject refuses the chirp outright.
+	ModeReject Mode = "reject"
+	// ModeFlag stores the chirp untouched and records it for review.
+	ModeFlag Mode = "flag"
+)
+
+const mask = "****"
+
+func ParseMode(s string) (Mode, error) {
+	switch Mode(strings.ToLower(s)) {
+	case "", ModeMask:
+		return ModeMask, nil
+	case ModeReject:
+		return ModeReject, nil
+	case ModeFlag:
+		return ModeFlag, nil
+	}
+	return "", fmt.Errorf("unknown profanity mode %q", s)
+}
+
+// Result describes the outcome of running text through a Filter.
+type Result struct {
+	// Masked is the input with every banned word replaced by asterisks.
+	// Punctuation and spacing are left as they were.
+	Masked string
+	// Matches lists the banned words found, lower-cased, in order of
+	// appearance and without duplicates.
+	Matches []string
+}
+
+// Filter holds the banned word list.  It is safe for concurrent use, so
+// the list can be swapped while requests are being served.
+type Filter struct {
+	mu    sync.RWMutex
+	words map[string]struct{}
+}
+
+func New(words []string) *Filter {
+	f := &Filter{}
+	f.SetWords(words)
+	return f
+}
+
+// SetWords replaces the banned word list.
+func (f *Filter) SetWords(words []string) {
+	set := make(map[string]struct{}, len(words))
+	for _, word := range words {
+		if normalized := Normalize(word); normalized != "" {
+			set[normalized] = struct{}{}
+		}
+	}
+	f.mu.Lock()
+	f.words = set
+	f.mu.Unlock()
+}
+
+// Words returns the banned word list in alphabetical order.
+func (f *Filter) Words() []string {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	words := make([]string, 0, len(f.words))
+	for word := range f.words {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+	return words
+}
+
+// Check finds banned words in text.  Words are runs of Unicode letters and
+// digits, so "kerfuffle!" and "(fornax)" both match while "kerfuffles"
+// does not.
+func (f *Filter) Check(text string) Result {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	var masked strings.Builder
+	var matches []string
+	seen := make(map[string]bool)
+	start := -1
+	flush := func(end int) {
+		if start < 0 {
+			return
+		}
+		word := text[start:end]
+		lower := strings.ToLower(word)
+		if _, banned := f.words[lower]; banned {
+			masked.WriteString(mask)
+			if !seen[lower] {
+				seen[lower] = true
+				matches = append(matches, lower)
+			}
+		} else {
+			masked.WriteString(word)
+		}
+		start = -1
+	}
+	for i, r := range text {
+		if isWordRune(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		flush(i)
+		masked.WriteRune(r)
+	}
+	flush(len(text))
+
+	return Result{
+		Masked:  masked.String(),
+		Matches: matches,
+	}
+}
+
+// Normalize lower-cases and trims a word.  It returns "" when the word
+// contains anything Check would treat as a boundary.
+func Normalize(word string) string {
+	word = strings.ToLower(strings.TrimSpace(word))
+	for _, r := range word {
+		if !isWordRune(r) {
+			return ""
+		}
+	}
+	return word
+}
+
+// LoadFile reads a word list with one word per line.  Blank lines and lines
+// starting with # are skipped.
+func LoadFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var words []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		word := Normalize(line)
+		if word == "" {
+			return nil, fmt.Errorf("%s: %q is not a single word", path, line)
+		}
+		words = append(words, word)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return words, nil
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r)
+}
@@ -0,0 +1,133 @@
+package profanity
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	filter := New([]string{"kerfuffle", "sharbert", "fornax"})
+
+	tests := []struct {
+		name    string
+		input   string
+		masked  string
+		matches []string
+	}{
+		{
+			name:   "Clean text is untouched",
+			input:  "I had something interesting for breakfast",
+			masked: "I had something interesting for breakfast",
+		},
+		{
+			name:    "Banned word is masked",
+			input:   "I really need a kerfuffle to go to bed sooner",
+			masked:  "I really need a **** to go to bed sooner",
+			matches: []string{"kerfuffle"},
+		},
+		{
+			name:    "Case is ignored",
+			input:   "I hear Mastodon is better than Chirpy. sharbert I need to migrate",
+			masked:  "I hear Mastodon is better than Chirpy. **** I need to migrate",
+			matches: []string{"sharbert"},
+		},
+		{
+			name:    "Punctuation is kept",
+			input:   "What a Kerfuffle! (fornax), \"sharbert\"?",
+			masked:  "What a ****! (****), \"****\"?",
+			matches: []string{"kerfuffle", "fornax", "sharbert"},
+		},
+		{
+			name:   "Words containing a banned word are kept",
+			input:  "kerfuffles fornaxian",
+			masked: "kerfuffles fornaxian",
+		},
+		{
+			name:    "Duplicates are reported once",
+			input:   "fornax FORNAX fornax",
+			masked:  "**** **** ****",
+			matches: []string{"fornax"},
+		},
+		{
+			name:    "Whitespace is preserved",
+			input:   "  fornax\tthen\nmore  ",
+			masked:  "  ****\tthen\nmore  ",
+			matches: []string{"fornax"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result := filter.Check(tc.input)
+			if result.Masked != tc.masked {
+				t.Errorf("Expected %q, got %q", tc.masked, result.Masked)
+			}
+			if !reflect.DeepEqual(result.Matches, tc.matches) {
+				t.Errorf("Expected matches %v, got %v", tc.matches, result.Matches)
+			}
+		})
+	}
+}
+
+func TestCheckUnicode(t *testing.T) {
+	filter := New([]string{"café", "straße"})
+
+	result := filter.Check("¡CAFÉ! la straße—café")
+	if result.Masked != "¡****! la ****—****" {
+		t.Errorf("Unexpected masking: %q", result.Masked)
+	}
+	if !reflect.DeepEqual(result.Matches, []string{"café", "straße"}) {
+		t.Errorf("Unexpected matches: %v", result.Matches)
+	}
+}
+
+func TestSetWords(t *testing.T) {
+	filter := New([]string{"fornax"})
+	filter.SetWords([]string{" Sharbert ", "not a word", ""})
+
+	if got := filter.Words(); !reflect.DeepEqual(got, []string{"sharbert"}) {
+		t.Errorf("Expected [sharbert], got %v", got)
+	}
+	if result := filter.Check("fornax"); len(result.Matches) != 0 {
+		t.Errorf("Old word list is still in use")
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	for input, want := range map[string]Mode{"": ModeMask, "mask": ModeMask, "REJECT": ModeReject, "flag": ModeFlag} {
+		got, err := ParseMode(input)
+		if err != nil {
+			t.Errorf("ParseMode(%q) returned an error: %v", input, err)
+		}
+		if got != want {
+			t.Errorf("ParseMode(%q) = %s, want %s", input, got, want)
+		}
+	}
+	if _, err := ParseMode("shout"); err == nil {
+		t.Errorf("Expected an error for an unknown mode")
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "words.txt")
+	contents := "# banned words\nKerfuffle\n\n  sharbert  \nfornax\n"
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	words, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(words, []string{"kerfuffle", "sharbert", "fornax"}) {
+		t.Errorf("Unexpected words: %v", words)
+	}
+
+	if err := os.WriteFile(path, []byte("two words\n"), 0o600); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := LoadFile(path); err == nil {
+		t.Errorf("Expected an error for a multi-word line")
+	}
+}
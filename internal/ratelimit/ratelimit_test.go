@@ -0,0 +1,94 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Limit
+		wantErr bool
+	}{
+		{"10/m", Limit{Rate: 10.0 / 60, Burst: 10}, false},
+		{"5/s", Limit{Rate: 5, Burst: 5}, false},
+		{"3600/h", Limit{Rate: 1, Burst: 3600}, false},
+		{"20/10s", Limit{Rate: 2, Burst: 20}, false},
+		{"10", Limit{}, true},
+		{"0/m", Limit{}, true},
+		{"ten/m", Limit{}, true},
+		{"10/fortnight", Limit{}, true},
+		{"10/-1s", Limit{}, true},
+	}
+	for _, tc := range tests {
+		got, err := ParseLimit(tc.input)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("ParseLimit(%q) error = %v, wantErr %v", tc.input, err, tc.wantErr)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("ParseLimit(%q) = %+v, want %+v", tc.input, got, tc.want)
+		}
+	}
+}
+
+func TestMemoryTake(t *testing.T) {
+	store := NewMemory()
+	ctx := context.Background()
+	limit := Limit{Rate: 1, Burst: 3}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i := 2; i >= 0; i-- {
+		result, err := store.Take(ctx, "ip:1", limit, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.Allowed || result.Remaining != i || result.Limit != 3 {
+			t.Fatalf("Take() = %+v, want allowed with %d remaining", result, i)
+		}
+	}
+	result, _ := store.Take(ctx, "ip:1", limit, now)
+	if result.Allowed {
+		t.Fatal("Take() on an empty bucket was allowed")
+	}
+	if result.RetryAfter != time.Second || result.Reset != 3*time.Second {
+		t.Errorf("RetryAfter, Reset = %s, %s, want 1s, 3s", result.RetryAfter, result.Reset)
+	}
+
+	// Other keys have their own bucket.
+	if result, _ := store.Take(ctx, "ip:2", limit, now); !result.Allowed {
+		t.Error("Take() for a different key was refused")
+	}
+
+	// Half a second refills half a token: still refused, and sooner.
+	result, _ = store.Take(ctx, "ip:1", limit, now.Add(500*time.Millisecond))
+	if result.Allowed || result.RetryAfter != 500*time.Millisecond {
+		t.Errorf("Take() after 0.5s = %+v, want refused with 500ms to wait", result)
+	}
+	result, _ = store.Take(ctx, "ip:1", limit, now.Add(time.Second))
+	if !result.Allowed || result.Remaining != 0 {
+		t.Errorf("Take() after 1s = %+v, want allowed with 0 remaining", result)
+	}
+	// The bucket never holds more than Burst tokens.
+	result, _ = store.Take(ctx, "ip:1", limit, now.Add(time.Hour))
+	if !result.Allowed || result.Remaining != 2 {
+		t.Errorf("Take() after an hour = %+v, want allowed with 2 remaining", result)
+	}
+}
+
+func TestMemorySweep(t *testing.T) {
+	store := NewMemory()
+	ctx := context.Background()
+	limit := Limit{Rate: 1, Burst: 1}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store.Take(ctx, "idle", limit, now)
+	store.Take(ctx, "busy", limit, now.Add(2*time.Minute))
+	if _, ok := store.buckets["idle"]; ok {
+		t.Error("a refilled bucket survived the sweep")
+	}
+	if _, ok := store.buckets["busy"]; !ok {
+		t.Error("the bucket in use was swept")
+	}
+}
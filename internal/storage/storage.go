@@ -0,0 +1,91 @@
+// Package storage keeps user-uploaded files behind the BlobStore interface
+// so the local disk can be swapped for an S3-compatible object store.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidKey is returned for keys that are empty, absolute or would
+	// escape the store.
+	ErrInvalidKey = errors.New("invalid storage key")
+	// ErrNotFound is returned by Get for a key that holds no blob.
+	ErrNotFound = errors.New("blob not found")
+)
+
+// BlobStore saves, fetches and removes blobs by key.  Keys are
+// slash-separated paths such as "chirps/<id>/<media id>.jpg".
+type BlobStore interface {
+	Put(ctx context.Context, key string, r io.Reader, contentType string) error
+	// Get returns the blob and its content type.  The caller closes it.
+	Get(ctx context.Context, key string) (io.ReadCloser, string, error)
+	// Delete removes the blob.  Deleting a missing blob is not an error.
+	Delete(ctx context.Context, key string) error
+	// SignedURL returns an address clients can fetch the blob from without
+	// credentials for at least expiry.
+	SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error)
+}
+
+// Config selects and configures a backend.
+type Config struct {
+	// Backend is "fs" or "s3".  Empty means "fs".
+	Backend string
+	// Dir and BaseURL configure the fs backend.
+	Dir     string
+	BaseURL string
+	// S3 configures the s3 backend.
+	S3 S3Config
+}
+
+// ConfigFromEnv reads STORAGE_BACKEND, MEDIA_DIR and the S3_* variables.
+func ConfigFromEnv() Config {
+	return Config{
+		Backend: os.Getenv("STORAGE_BACKEND"),
+		Dir:     os.Getenv("MEDIA_DIR"),
+		BaseURL: "/media",
+		S3: S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Region:    os.Getenv("S3_REGION"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+		},
+	}
+}
+
+// Open returns the backend cfg asks for.  On error the BlobStore is nil,
+// not a nil *FS or *S3.
+func Open(cfg Config) (BlobStore, error) {
+	switch strings.ToLower(cfg.Backend) {
+	case "", "fs":
+		dir := cfg.Dir
+		if dir == "" {
+			dir = "media"
+		}
+		store, err := NewFS(dir, cfg.BaseURL)
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	case "s3":
+		store, err := NewS3(cfg.S3, nil)
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	}
+	return nil, fmt.Errorf("unknown storage backend %q", cfg.Backend)
+}
+
+func validKey(key string) bool {
+	return key != "" && !strings.HasPrefix(key, "/") && path.Clean(key) == key &&
+		key != ".." && !strings.HasPrefix(key, "../")
+}
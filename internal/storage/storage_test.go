@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFS(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFS(dir, "/media/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	key := "chirps/abc/1.png"
+	if err := store.Put(ctx, key, strings.NewReader("image bytes"), "image/png"); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, "chirps", "abc", "1.png"))
+	if err != nil {
+		t.Fatalf("reading stored file: %v", err)
+	}
+	if string(got) != "image bytes" {
+		t.Errorf("stored %q, want %q", got, "image bytes")
+	}
+	if url, err := store.SignedURL(ctx, key, time.Hour); err != nil || url != "/media/chirps/abc/1.png" {
+		t.Errorf("SignedURL() = %q, %v, want %q", url, err, "/media/chirps/abc/1.png")
+	}
+	blob, contentType, err := store.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	got, _ = io.ReadAll(blob)
+	blob.Close()
+	if string(got) != "image bytes" || contentType != "image/png" {
+		t.Errorf("Get() = %q, %q, want %q, %q", got, contentType, "image bytes", "image/png")
+	}
+
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "chirps", "abc", "1.png")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("file still exists after Delete(): %v", err)
+	}
+	if err := store.Delete(ctx, key); err != nil {
+		t.Errorf("Delete() of a missing blob error = %v", err)
+	}
+	if _, _, err := store.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() of a missing blob error = %v, want %v", err, ErrNotFound)
+	}
+	if _, _, err := store.Get(ctx, "chirps/abc"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() of a directory error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestFSInvalidKeys(t *testing.T) {
+	store, err := NewFS(t.TempDir(), "/media")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"", "/etc/passwd", "../escape", "a/../../b", "a//b", ".."} {
+		err := store.Put(context.Background(), key, strings.NewReader("x"), "image/png")
+		if !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Put(%q) error = %v, want %v", key, err, ErrInvalidKey)
+		}
+	}
+}
+
+func TestOpen(t *testing.T) {
+	dir := t.TempDir()
+	store, err := Open(Config{Dir: dir, BaseURL: "/media"})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if fs, ok := store.(*FS); !ok {
+		t.Errorf("Open() with no backend = %T, want *FS", store)
+	} else if fs.Path() != dir {
+		t.Errorf("Open() stores under %q, want %q", fs.Path(), dir)
+	}
+	store, err = Open(Config{Backend: "S3", S3: S3Config{
+		Endpoint:  "http://localhost:9000",
+		Bucket:    "chirpy",
+		AccessKey: "key",
+		SecretKey: "secret",
+	}})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if _, ok := store.(*S3); !ok {
+		t.Errorf("Open() with backend S3 = %T, want *S3", store)
+	}
+	store, err = Open(Config{Backend: "s3"})
+	if err == nil {
+		t.Error("Open() of s3 without credentials succeeded")
+	}
+	if store != nil {
+		t.Errorf("Open() of s3 without credentials = %#v, want nil", store)
+	}
+	if _, err := Open(Config{Backend: "ftp"}); err == nil {
+		t.Error("Open() of an unknown backend succeeded")
+	}
+}
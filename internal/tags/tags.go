@@ -0,0 +1,70 @@
+package tags
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxLength caps how many characters of a tag or handle are kept.
+const MaxLength = 64
+
+// Hashtags returns the #hashtags in text, lower-cased, without the leading
+// '#', in order of first appearance and without duplicates.  A hashtag must
+// contain at least one letter, so "#1" is not a tag.
+func Hashtags(text string) []string {
+	return extract(text, '#', true)
+}
+
+// Mentions returns the @handles in text, lower-cased, without the leading
+// '@', in order of first appearance and without duplicates.  A '@' that
+// follows a word character, as in an email address, is not a mention.
+func Mentions(text string) []string {
+	return extract(text, '@', false)
+}
+
+func extract(text string, marker rune, needLetter bool) []string {
+	var found []string
+	seen := make(map[string]bool)
+	prev := ' '
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if r != marker || isTagRune(prev) {
+			prev = r
+			i += size
+			continue
+		}
+		start := i + size
+		end := start
+		hasLetter := false
+		for end < len(text) {
+			next, nextSize := utf8.DecodeRuneInString(text[end:])
+			if !isTagRune(next) {
+				break
+			}
+			if unicode.IsLetter(next) {
+				hasLetter = true
+			}
+			end += nextSize
+		}
+		if end > start && (hasLetter || !needLetter) {
+			tag := strings.ToLower(text[start:end])
+			if utf8.RuneCountInString(tag) <= MaxLength && !seen[tag] {
+				seen[tag] = true
+				found = append(found, tag)
+			}
+		}
+		if end == start {
+			prev = r
+			i = start
+			continue
+		}
+		prev, _ = utf8.DecodeLastRuneInString(text[:end])
+		i = end
+	}
+	return found
+}
+
+func isTagRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,50 @@
+package tags
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHashtags(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{"No tags", "just a chirp", nil},
+		{"Single tag", "loving #golang today", []string{"golang"}},
+		{"Lower-cased and deduplicated", "#Go #go #GO", []string{"go"}},
+		{"Punctuation ends a tag", "(#chirpy), #boot_dev!", []string{"chirpy", "boot_dev"}},
+		{"Numbers alone are not tags", "issue #42 and #2024goals", []string{"2024goals"}},
+		{"Mid-word hash is ignored", "C#sharp and a#b", nil},
+		{"Unicode letters", "#café #日本", []string{"café", "日本"}},
+		{"Bare hash", "# alone and ##double", []string{"double"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Hashtags(tc.input); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Hashtags(%q) = %v, want %v", tc.input, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMentions(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{"Single mention", "hi @alice", []string{"alice"}},
+		{"Several mentions", "@Alice, @bob_99 and @alice again", []string{"alice", "bob_99"}},
+		{"Email addresses are skipped", "mail me at someone@example.com", nil},
+		{"Numeric handles are allowed", "@1234", []string{"1234"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Mentions(tc.input); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Mentions(%q) = %v, want %v", tc.input, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package trending
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Window is a span of recent history that hashtags are ranked over.  Uses
+// inside the window are weighted by exp(-ln2 * age / HalfLife), so a use
+// HalfLife ago counts half as much as one right now.
+type Window struct {
+	Name     string
+	Span     time.Duration
+	HalfLife time.Duration
+}
+
+// Tag is one ranked hashtag.
+type Tag struct {
+	Tag   string  `json:"tag"`
+	Uses  int64   `json:"uses"`
+	Score float64 `json:"score"`
+}
+
+// Loader computes the ranking for one window as of now.
+type Loader func(ctx context.Context, window Window, now time.Time) ([]Tag, error)
+
+// DefaultWindows is used when none are configured.  The first window in
+// any list is the one served when a request does not name a window.
+const DefaultWindows = "24h,1h,7d"
+
+// ParseWindows reads a comma-separated list of spans such as "1h,24h,7d".
+// Spans accept Go duration syntax plus a "d" suffix for days.  Each
+// window's half-life is a quarter of its span.
+func ParseWindows(s string) ([]Window, error) {
+	var windows []Window
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		span, err := parseSpan(name)
+		if err != nil {
+			return nil, err
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("window %s is listed twice", name)
+		}
+		seen[name] = true
+		windows = append(windows, Window{
+			Name:     name,
+			Span:     span,
+			HalfLife: span / 4,
+		})
+	}
+	if len(windows) == 0 {
+		return nil, fmt.Errorf("no trending windows configured")
+	}
+	return windows, nil
+}
+
+func parseSpan(name string) (time.Duration, error) {
+	if days, found := strings.CutSuffix(name, "d"); found {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid window %q", name)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	span, err := time.ParseDuration(name)
+	if err != nil || span <= 0 {
+		return 0, fmt.Errorf("invalid window %q", name)
+	}
+	return span, nil
+}
+
+type snapshot struct {
+	tags      []Tag
+	updatedAt time.Time
+}
+
+// Cache holds the latest ranking for each window.  Requests only ever read
+// from it; the heavy aggregate runs in Run.
+type Cache struct {
+	windows []Window
+	load    Loader
+
+	mu        sync.RWMutex
+	snapshots map[string]snapshot
+}
+
+func NewCache(windows []Window, load Loader) *Cache {
+	return &Cache{
+		windows:   windows,
+		load:      load,
+		snapshots: make(map[string]snapshot),
+	}
+}
+
+// Window looks up a configured window by name.
+func (c *Cache) Window(name string) (Window, bool) {
+	for _, window := range c.windows {
+		if window.Name == name {
+			return window, true
+		}
+	}
+	return Window{}, false
+}
+
+// Default returns the first configured window.
+func (c *Cache) Default() (Window, bool) {
+	if len(c.windows) == 0 {
+		return Window{}, false
+	}
+	return c.windows[0], true
+}
+
+// Get returns the cached ranking for a window and when it was computed.
+// The zero time means the window has not been computed yet.
+func (c *Cache) Get(name string) ([]Tag, time.Time) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	snap := c.snapshots[name]
+	return snap.tags, snap.updatedAt
+}
+
+// Refresh recomputes every window.  A window that fails keeps its previous
+// ranking.
+func (c *Cache) Refresh(ctx context.Context) error {
+	now := time.Now()
+	var firstErr error
+	for _, window := range c.windows {
+		tags, err := c.load(ctx, window, now)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("window %s: %w", window.Name, err)
+			}
+			continue
+		}
+		c.mu.Lock()
+		c.snapshots[window.Name] = snapshot{tags: tags, updatedAt: now}
+		c.mu.Unlock()
+	}
+	return firstErr
+}
+
+// Run refreshes the cache immediately and then every interval until ctx is
+// cancelled.
+func (c *Cache) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := c.Refresh(ctx); err != nil {
+			log.Printf("An error occurred refreshing trending hashtags: %s", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
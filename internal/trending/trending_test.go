@@ -0,0 +1,83 @@
+package trending
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseWindows(t *testing.T) {
+	windows, err := ParseWindows(DefaultWindows)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want := []Window{
+		{Name: "24h", Span: 24 * time.Hour, HalfLife: 6 * time.Hour},
+		{Name: "1h", Span: time.Hour, HalfLife: 15 * time.Minute},
+		{Name: "7d", Span: 7 * 24 * time.Hour, HalfLife: 42 * time.Hour},
+	}
+	if len(windows) != len(want) {
+		t.Fatalf("Expected %d windows, got %d", len(want), len(windows))
+	}
+	for i := range want {
+		if windows[i] != want[i] {
+			t.Errorf("Window %d: got %+v, want %+v", i, windows[i], want[i])
+		}
+	}
+
+	for _, bad := range []string{"", "soon", "0d", "-1h", "1h,1h"} {
+		if _, err := ParseWindows(bad); err == nil {
+			t.Errorf("ParseWindows(%q) should have failed", bad)
+		}
+	}
+}
+
+func TestCacheRefresh(t *testing.T) {
+	windows, _ := ParseWindows("1h,24h")
+	fail := false
+	calls := 0
+	cache := NewCache(windows, func(ctx context.Context, window Window, now time.Time) ([]Tag, error) {
+		calls++
+		if fail && window.Name == "24h" {
+			return nil, errors.New("database is down")
+		}
+		return []Tag{{Tag: window.Name, Uses: int64(calls), Score: 1}}, nil
+	})
+
+	if tags, updated := cache.Get("1h"); tags != nil || !updated.IsZero() {
+		t.Fatalf("Expected an empty cache before the first refresh")
+	}
+	if err := cache.Refresh(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	tags, updated := cache.Get("24h")
+	if len(tags) != 1 || tags[0].Tag != "24h" || updated.IsZero() {
+		t.Fatalf("Unexpected 24h ranking: %v at %v", tags, updated)
+	}
+
+	fail = true
+	if err := cache.Refresh(context.Background()); err == nil {
+		t.Fatalf("Expected the failing window to be reported")
+	}
+	if tags, _ := cache.Get("1h"); tags[0].Uses != 3 {
+		t.Errorf("Expected the 1h window to be refreshed, got %v", tags)
+	}
+	if tags, _ := cache.Get("24h"); tags[0].Uses != 2 {
+		t.Errorf("Expected the 24h window to keep its last ranking, got %v", tags)
+	}
+
+	if _, ok := cache.Window("7d"); ok {
+		t.Errorf("7d was not configured")
+	}
+}
+
+func TestCacheDefault(t *testing.T) {
+	windows, _ := ParseWindows("1h,7d")
+	if window, ok := NewCache(windows, nil).Default(); !ok || window.Name != "1h" {
+		t.Errorf("Default() = %+v, %v, want the 1h window", window, ok)
+	}
+	if _, ok := NewCache(nil, nil).Default(); ok {
+		t.Errorf("Default() of a cache without windows should fail")
+	}
+}
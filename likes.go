@@ -0,0 +1,73 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/interyx/chirpy/internal/database"
+)
+
+func (cfg *apiConfig) likeChirpHandler(w http.ResponseWriter, req *http.Request) {
+	userID, ok := cfg.authenticate(w, req)
+	if !ok {
+		return
+	}
+	id, err := uuid.Parse(req.PathValue("id"))
+	if err != nil {
+		respondWithError(w, 400, errCodeInvalidID, "Chirp ID is not a valid UUID")
+		return
+	}
+	_, err = cfg.db.GetChirp(req.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 404, errCodeChirpNotFound, "Chirp not found")
+		return
+	}
+	if err != nil {
+		log.Printf("An error occurred retrieving the record: %s", err)
+		respondWithError(w, 500, errCodeInternal, "An error occurred retrieving the chirp")
+		return
+	}
+	likeParams := database.LikeChirpParams{
+		UserID:    userID,
+		ChirpID:   id,
+		CreatedAt: time.Now(),
+	}
+	err = cfg.db.LikeChirp(req.Context(), likeParams)
+	if err != nil {
+		log.Printf("An error occurred liking the chirp: %s", err)
+		respondWithError(w, 500, errCodeInternal, "An error occurred liking the chirp")
+		return
+	}
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) unlikeChirpHandler(w http.ResponseWriter, req *http.Request) {
+	userID, ok := cfg.authenticate(w, req)
+	if !ok {
+		return
+	}
+	id, err := uuid.Parse(req.PathValue("id"))
+	if err != nil {
+		respondWithError(w, 400, errCodeInvalidID, "Chirp ID is not a valid UUID")
+		return
+	}
+	unlikeParams := database.UnlikeChirpParams{
+		UserID:  userID,
+		ChirpID: id,
+	}
+	rows, err := cfg.db.UnlikeChirp(req.Context(), unlikeParams)
+	if err != nil {
+		log.Printf("An error occurred unliking the chirp: %s", err)
+		respondWithError(w, 500, errCodeInternal, "An error occurred unliking the chirp")
+		return
+	}
+	if rows == 0 {
+		respondWithError(w, 404, errCodeNotLiked, "You have not liked that chirp")
+		return
+	}
+	w.WriteHeader(204)
+}
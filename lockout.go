@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/interyx/chirpy/internal/auth"
+	"github.com/interyx/chirpy/internal/database"
+	"github.com/interyx/chirpy/internal/lockout"
+)
+
+// ipLoginPolicy is deliberately looser than the account policy, since
+// many users can share one address, and never locks.
+var ipLoginPolicy = lockout.Policy{
+	FreeAttempts: 20,
+	BaseDelay:    time.Second,
+	MaxDelay:     time.Minute,
+	ResetAfter:   time.Hour,
+}
+
+// dummyPasswordHash is compared against when the email is unknown, so a
+// login takes as long whether or not the account exists.
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, err := auth.HashPassword("chirpy-dummy-password")
+	if err != nil {
+		log.Printf("An error occurred hashing the dummy password: %s", err)
+	}
+	return hash
+})
+
+// loginPolicyFromEnv reads LOGIN_BACKOFF_AFTER, LOGIN_LOCK_AFTER and
+// LOGIN_LOCK_DURATION over the default account policy.
+func loginPolicyFromEnv() lockout.Policy {
+	policy := lockout.DefaultPolicy
+	if value := os.Getenv("LOGIN_BACKOFF_AFTER"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			fmt.Printf("Invalid LOGIN_BACKOFF_AFTER %q, falling back to %d\n", value, policy.FreeAttempts)
+		} else {
+			policy.FreeAttempts = n
+		}
+	}
+	if value := os.Getenv("LOGIN_LOCK_AFTER"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			fmt.Printf("Invalid LOGIN_LOCK_AFTER %q, falling back to %d\n", value, policy.LockAfter)
+		} else {
+			policy.LockAfter = n
+		}
+	}
+	if value := os.Getenv("LOGIN_LOCK_DURATION"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			fmt.Printf("Invalid LOGIN_LOCK_DURATION %q, falling back to %s\n", value, policy.LockFor)
+		} else {
+			policy.LockFor = d
+		}
+	}
+	return policy
+}
+
+// respondLoginWait tells the client how long to wait before trying again.
+func respondLoginWait(w http.ResponseWriter, wait time.Duration, locked bool) {
+	retryAfter := max(ceilSeconds(wait), 1)
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	if locked {
+		msg := fmt.Sprintf("This account is temporarily locked, try again in %d seconds", retryAfter)
+		respondWithError(w, 423, errCodeAccountLocked, msg)
+		return
+	}
+	msg := fmt.Sprintf("Too many failed attempts, try again in %d seconds", retryAfter)
+	respondWithError(w, 429, errCodeTooManyAttempts, msg)
+}
+
+// loginState reads an account's failure history off its row.
+func loginState(user database.User) lockout.State {
+	return lockout.State{
+		Failures:    int(user.FailedLogins),
+		LastFailure: user.LastFailedLoginAt.Time,
+		LockedUntil: user.LockedUntil.Time,
+	}
+}
+
+// checkLogin applies the account backoff before a password is checked.
+// Unknown emails are tracked in memory under the same policy as accounts,
+// so the responses never reveal which emails are registered.  It writes
+// the response and returns false when the attempt must wait.
+func (cfg *apiConfig) checkLogin(w http.ResponseWriter, email string, user database.User, found bool, now time.Time) bool {
+	var wait time.Duration
+	var locked bool
+	if found {
+		wait, locked = cfg.accountLogins.Policy().Check(loginState(user), now)
+	} else {
+		wait, locked = cfg.accountLogins.Check(unknownEmailKey(email), now)
+	}
+	if wait > 0 {
+		respondLoginWait(w, wait, locked)
+		return false
+	}
+	return true
+}
+
+// recordLoginFailure counts a wrong password against the address and the
+// account, or against the email when there is no account.  The account's
+// count is incremented in the database rather than written back from the
+// row read before the password check, so concurrent failures all count.
+func (cfg *apiConfig) recordLoginFailure(ctx context.Context, ip, email string, user database.User, found bool, now time.Time) error {
+	// The columns are TIMESTAMP without a zone, which lib/pq reads back as
+	// UTC, so they must be written in UTC too.
+	now = now.UTC()
+	cfg.ipLogins.Fail(ip, now)
+	if !found {
+		cfg.accountLogins.Fail(unknownEmailKey(email), now)
+		return nil
+	}
+	policy := cfg.accountLogins.Policy()
+	row, err := cfg.db.RecordLoginFailure(ctx, database.RecordLoginFailureParams{
+		ResetBefore: now.Add(-policy.ResetAfter),
+		Now:         now,
+		ID:          user.ID,
+	})
+	if err != nil {
+		return err
+	}
+	state := lockout.State{
+		Failures:    int(row.FailedLogins),
+		LastFailure: row.LastFailedLoginAt.Time,
+		LockedUntil: row.LockedUntil.Time,
+	}
+	locked := policy.Lock(state, now)
+	if locked.LockedUntil.Equal(state.LockedUntil) {
+		return nil
+	}
+	return cfg.db.LockUser(ctx, database.LockUserParams{
+		ID:          user.ID,
+		LockedUntil: sql.NullTime{Time: locked.LockedUntil, Valid: true},
+	})
+}
+
+func unknownEmailKey(email string) string {
+	return "email:" + strings.ToLower(email)
+}
+
+func (cfg *apiConfig) unlockUserHandler(w http.ResponseWriter, req *http.Request) {
+	if !cfg.requireAdmin(w, req) {
+		return
+	}
+	id, err := uuid.Parse(req.PathValue("id"))
+	if err != nil {
+		respondWithError(w, 400, errCodeInvalidID, "User ID is not a valid UUID")
+		return
+	}
+	_, err = cfg.db.GetUserByID(req.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 404, errCodeUserNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		log.Printf("An error occurred retrieving the user: %s", err)
+		respondWithError(w, 500, errCodeInternal, "An error occurred retrieving the user")
+		return
+	}
+	err = cfg.db.ResetLoginFailures(req.Context(), id)
+	if err != nil {
+		log.Printf("An error occurred unlocking the user: %s", err)
+		respondWithError(w, 500, errCodeInternal, "An error occurred unlocking the user")
+		return
+	}
+	w.WriteHeader(204)
+}
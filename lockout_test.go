@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/interyx/chirpy/internal/database"
+	"github.com/interyx/chirpy/internal/lockout"
+)
+
+func TestLoginLockoutUnknownEmail(t *testing.T) {
+	policy := lockout.Policy{FreeAttempts: 1, BaseDelay: time.Second, MaxDelay: time.Minute, LockAfter: 3, LockFor: time.Minute, ResetAfter: time.Hour}
+	cfg := &apiConfig{
+		accountLogins: lockout.NewTracker(policy),
+		ipLogins:      lockout.NewTracker(ipLoginPolicy),
+	}
+	now := time.Now()
+	email := "Nobody@example.com"
+	want := []int{0, 0, 429, 0, 423}
+	for i, status := range want {
+		rec := httptest.NewRecorder()
+		ok := cfg.checkLogin(rec, email, database.User{}, false, now)
+		if status == 0 {
+			if !ok {
+				t.Fatalf("Attempt %d: expected to be let through, got %d", i, rec.Code)
+			}
+			cfg.recordLoginFailure(context.Background(), "ip:192.0.2.1", email, database.User{}, false, now)
+			continue
+		}
+		if ok || rec.Code != status {
+			t.Fatalf("Attempt %d: expected status %d, got %d", i, status, rec.Code)
+		}
+		if rec.Header().Get("Retry-After") == "" {
+			t.Errorf("Attempt %d: expected a Retry-After header", i)
+		}
+		// Wait out the backoff before the next attempt.
+		now = now.Add(2 * time.Second)
+	}
+}
+
+// TestRecordLoginFailureConcurrent checks how Postgres handles concurrent
+// updates to one user row.
+func TestRecordLoginFailureConcurrent(t *testing.T) {
+	db, queries := openTestDB(t)
+	ctx := context.Background()
+
+	// failConcurrently makes n wrong-password attempts at once, each from
+	// the same copy of the row, as requests that read it before checking
+	// the password would.
+	failConcurrently := func(t *testing.T, policy lockout.Policy, n int) database.User {
+		t.Helper()
+		now := time.Now()
+		user := createTestUser(t, db, queries, "unused")
+		cfg := &apiConfig{
+			db:            queries,
+			accountLogins: lockout.NewTracker(policy),
+			ipLogins:      lockout.NewTracker(ipLoginPolicy),
+		}
+		var wg sync.WaitGroup
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				err := cfg.recordLoginFailure(ctx, "ip:192.0.2.1", user.Email, user, true, now)
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+		user, err := queries.GetUserByID(ctx, user.ID)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return user
+	}
+
+	t.Run("Every failure counts", func(t *testing.T) {
+		policy := lockout.DefaultPolicy
+		policy.LockAfter = 0
+		user := failConcurrently(t, policy, 20)
+		if user.FailedLogins != 20 {
+			t.Errorf("Expected 20 failed logins, got %d", user.FailedLogins)
+		}
+	})
+
+	t.Run("Concurrent failures lock the account", func(t *testing.T) {
+		user := failConcurrently(t, lockout.DefaultPolicy, 20)
+		if !user.LockedUntil.Valid || !user.LockedUntil.Time.After(time.Now()) {
+			t.Errorf("Expected the account to be locked, got locked_until %v", user.LockedUntil)
+		}
+	})
+}
+
+// TestRecordLoginFailureTimeZone fails from a clock east of UTC, which is
+// where a lock read back from a TIMESTAMP column used to end hours early.
+func TestRecordLoginFailureTimeZone(t *testing.T) {
+	db, queries := openTestDB(t)
+	policy := lockout.Policy{LockAfter: 1, LockFor: time.Minute, ResetAfter: time.Hour}
+	cfg := &apiConfig{
+		db:            queries,
+		accountLogins: lockout.NewTracker(policy),
+		ipLogins:      lockout.NewTracker(ipLoginPolicy),
+	}
+	user := createTestUser(t, db, queries, "unused")
+	now := time.Now().In(time.FixedZone("UTC+5", 5*60*60))
+	if err := cfg.recordLoginFailure(context.Background(), "ip:192.0.2.1", user.Email, user, true, now); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	user, err := queries.GetUserByID(context.Background(), user.ID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	wait, locked := policy.Check(loginState(user), now)
+	if !locked || wait <= 0 || wait > time.Minute {
+		t.Errorf("Expected a lock of up to a minute, got %s (locked %v)", wait, locked)
+	}
+}
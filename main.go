@@ -2,20 +2,44 @@ package main
 
 import _ "github.com/lib/pq"
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"github.com/interyx/chirpy/internal/database"
+	"github.com/interyx/chirpy/internal/lockout"
+	"github.com/interyx/chirpy/internal/mailer"
+	"github.com/interyx/chirpy/internal/profanity"
+	"github.com/interyx/chirpy/internal/ratelimit"
+	"github.com/interyx/chirpy/internal/storage"
+	"github.com/interyx/chirpy/internal/trending"
 	"github.com/joho/godotenv"
 	"net/http"
 	"os"
+	"path"
+	"path/filepath"
+	"strings"
 	"sync/atomic"
+	"time"
 )
 
 type apiConfig struct {
 	db             *database.Queries
+	sqlDB          *sql.DB
 	fileserverHits atomic.Int32
 	platform       string
 	signJWT        string
+	polkaKey       string
+	adminKey       string
+	profanity      *profanity.Filter
+	profanityMode  profanity.Mode
+	profanityWords []string
+	trending       *trending.Cache
+	media          storage.BlobStore
+	rateLimiter    ratelimit.Store
+	accountLogins  *lockout.Tracker
+	ipLogins       *lockout.Tracker
+	mailer         mailer.Mailer
+	publicURL      string
 }
 
 func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
@@ -25,9 +49,36 @@ func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
 	})
 }
 
-func fileHandler() http.Handler {
+// fileHandler serves the working directory under /app/.  Requests for
+// anything inside the hidden directories get a 404, so a mail or upload
+// directory configured inside the tree cannot be listed or read here.
+func fileHandler(hidden ...string) http.Handler {
 	server := http.FileServer(http.Dir("."))
-	return http.StripPrefix("/app/", server)
+	root, _ := filepath.Abs(".")
+	var blocked []string
+	for _, dir := range hidden {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(root, abs)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		// Lowercase so a case-insensitive filesystem cannot be used to
+		// get around the check.
+		blocked = append(blocked, strings.ToLower(path.Clean("/"+filepath.ToSlash(rel))))
+	}
+	return http.StripPrefix("/app/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.ToLower(path.Clean("/" + r.URL.Path))
+		for _, dir := range blocked {
+			if name == dir || strings.HasPrefix(name, strings.TrimSuffix(dir, "/")+"/") {
+				http.NotFound(w, r)
+				return
+			}
+		}
+		server.ServeHTTP(w, r)
+	}))
 }
 
 func main() {
@@ -38,6 +89,65 @@ func main() {
 	dbURL := os.Getenv("DB_URL")
 	platform := os.Getenv("PLATFORM")
 	tokenSecret := os.Getenv("SIGN_KEY")
+	polkaKey := os.Getenv("POLKA_KEY")
+	adminKey := os.Getenv("ADMIN_KEY")
+	profanityMode, err := profanity.ParseMode(os.Getenv("PROFANITY_MODE"))
+	if err != nil {
+		fmt.Printf("%s, falling back to %s\n", err, profanity.ModeMask)
+		profanityMode = profanity.ModeMask
+	}
+	var profanityWords []string
+	if path := os.Getenv("PROFANITY_WORDS_FILE"); path != "" {
+		profanityWords, err = profanity.LoadFile(path)
+		if err != nil {
+			fmt.Printf("An error occurred loading the banned word list: %s\n", err)
+		}
+	}
+	windowList := os.Getenv("TRENDING_WINDOWS")
+	if windowList == "" {
+		windowList = trending.DefaultWindows
+	}
+	trendingWindows, err := trending.ParseWindows(windowList)
+	if err != nil {
+		fmt.Printf("%s, falling back to %s\n", err, trending.DefaultWindows)
+		trendingWindows, _ = trending.ParseWindows(trending.DefaultWindows)
+	}
+	trendingRefresh := time.Minute
+	if value := os.Getenv("TRENDING_REFRESH"); value != "" {
+		trendingRefresh, err = time.ParseDuration(value)
+		if err != nil || trendingRefresh <= 0 {
+			fmt.Printf("Invalid TRENDING_REFRESH %q, falling back to 1m\n", value)
+			trendingRefresh = time.Minute
+		}
+	}
+	// Uploads must not quietly land on one instance's disk when the
+	// configured backend is broken, so this is fatal.
+	mediaStore, err := storage.Open(storage.ConfigFromEnv())
+	if err != nil {
+		fmt.Printf("An error occurred opening blob storage: %s\n", err)
+		os.Exit(1)
+	}
+	// Uploads and mail are only served through their own handlers, never
+	// as files under /app/.
+	var hiddenDirs []string
+	if fs, ok := mediaStore.(*storage.FS); ok {
+		hiddenDirs = append(hiddenDirs, fs.Path())
+	}
+	// Without working mail nobody can verify an address, and so nobody
+	// new can chirp, so this is fatal too.
+	mail, err := mailer.Open(mailer.ConfigFromEnv())
+	if err != nil {
+		fmt.Printf("An error occurred opening the mailer: %s\n", err)
+		os.Exit(1)
+	}
+	if dir, ok := mail.(*mailer.Dir); ok {
+		fmt.Printf("Writing outgoing mail to %s\n", dir.Path())
+		hiddenDirs = append(hiddenDirs, dir.Path())
+	}
+	publicURL := os.Getenv("PUBLIC_URL")
+	if publicURL == "" {
+		publicURL = "http://localhost:8080"
+	}
 	db, err := sql.Open("postgres", dbURL)
 	if err != nil {
 		fmt.Printf("An error occurred opening the database: %s\n", err)
@@ -45,19 +155,76 @@ func main() {
 	muxer := http.NewServeMux()
 	dbQueries := database.New(db)
 	apiCfg := apiConfig{
-		db:       dbQueries,
-		platform: platform,
-		signJWT:  tokenSecret,
+		db:             dbQueries,
+		sqlDB:          db,
+		platform:       platform,
+		signJWT:        tokenSecret,
+		polkaKey:       polkaKey,
+		adminKey:       adminKey,
+		profanity:      profanity.New(profanityWords),
+		profanityMode:  profanityMode,
+		profanityWords: profanityWords,
+		media:          mediaStore,
+		rateLimiter:    ratelimit.NewMemory(),
+		accountLogins:  lockout.NewTracker(loginPolicyFromEnv()),
+		ipLogins:       lockout.NewTracker(ipLoginPolicy),
+		mailer:         mail,
+		publicURL:      strings.TrimSuffix(publicURL, "/"),
 	}
-	muxer.Handle("/app/", apiCfg.middlewareMetricsInc(fileHandler()))
+	loginLimit := rateLimitFromEnv("RATE_LIMIT_LOGIN", "10/m")
+	signupLimit := rateLimitFromEnv("RATE_LIMIT_SIGNUP", "5/m")
+	chirpLimit := rateLimitFromEnv("RATE_LIMIT_CHIRPS", "30/m")
+	resendLimit := rateLimitFromEnv("RATE_LIMIT_VERIFY_RESEND", "3/h")
+	forgotLimit := rateLimitFromEnv("RATE_LIMIT_PASSWORD_FORGOT", "5/h")
+	apiCfg.trending = trending.NewCache(trendingWindows, apiCfg.loadTrending)
+	go apiCfg.trending.Run(context.Background(), trendingRefresh)
+	err = apiCfg.reloadBannedWords(context.Background())
+	if err != nil {
+		fmt.Printf("An error occurred loading banned words from the database: %s\n", err)
+	}
+	muxer.Handle("/app/", apiCfg.middlewareMetricsInc(fileHandler(hiddenDirs...)))
+	muxer.HandleFunc("GET /media/", apiCfg.mediaHandler)
 	muxer.HandleFunc("GET /api/healthz", readyHandler)
 	muxer.HandleFunc("GET /admin/metrics", apiCfg.writeCountHandler)
 	muxer.HandleFunc("POST /admin/reset", apiCfg.resetHandler)
-	muxer.HandleFunc("POST /api/chirps", apiCfg.createChirpHandler)
-	muxer.HandleFunc("POST /api/users", apiCfg.addUser)
+	muxer.HandleFunc("GET /admin/banned-words", apiCfg.getBannedWordsHandler)
+	muxer.HandleFunc("POST /admin/banned-words", apiCfg.addBannedWordHandler)
+	muxer.HandleFunc("DELETE /admin/banned-words/{word}", apiCfg.deleteBannedWordHandler)
+	muxer.HandleFunc("GET /admin/flagged-chirps", apiCfg.getFlaggedChirpsHandler)
+	muxer.HandleFunc("POST /admin/users/{id}/unlock", apiCfg.unlockUserHandler)
+	muxer.Handle("POST /api/chirps", apiCfg.middlewareRateLimit("chirps", chirpLimit, apiCfg.userOrIP, http.HandlerFunc(apiCfg.createChirpHandler)))
+	muxer.Handle("POST /api/users", apiCfg.middlewareRateLimit("signup", signupLimit, clientIP, http.HandlerFunc(apiCfg.addUser)))
+	muxer.HandleFunc("PUT /api/users", apiCfg.updateUser)
+	muxer.HandleFunc("PUT /api/users/avatar", apiCfg.uploadAvatarHandler)
+	muxer.HandleFunc("POST /api/users/mfa", apiCfg.enrollMFAHandler)
+	muxer.Handle("POST /api/users/mfa/confirm", apiCfg.middlewareRateLimit("mfa-confirm", loginLimit, apiCfg.userOrIP, http.HandlerFunc(apiCfg.confirmMFAHandler)))
+	muxer.Handle("DELETE /api/users/mfa", apiCfg.middlewareRateLimit("mfa-disable", loginLimit, apiCfg.userOrIP, http.HandlerFunc(apiCfg.disableMFAHandler)))
+	muxer.HandleFunc("GET /api/users/{handle}", apiCfg.getProfileHandler)
+	muxer.HandleFunc("POST /api/users/{id}/follow", apiCfg.followHandler)
+	muxer.HandleFunc("DELETE /api/users/{id}/follow", apiCfg.unfollowHandler)
+	muxer.HandleFunc("GET /api/users/{id}/followers", apiCfg.getFollowersHandler)
+	muxer.HandleFunc("GET /api/users/{id}/following", apiCfg.getFollowingHandler)
+	muxer.HandleFunc("GET /api/users/{id}/mentions", apiCfg.getMentionsHandler)
+	muxer.HandleFunc("GET /api/timeline", apiCfg.timelineHandler)
+	muxer.HandleFunc("GET /api/hashtags/{tag}/chirps", apiCfg.getHashtagChirpsHandler)
+	muxer.HandleFunc("GET /api/trending", apiCfg.trendingHandler)
 	muxer.HandleFunc("GET /api/chirps", apiCfg.getChirpsHandler)
+	muxer.HandleFunc("GET /api/chirps/search", apiCfg.searchChirpsHandler)
 	muxer.HandleFunc("GET /api/chirps/{id}", apiCfg.getChirpHandler)
-	muxer.HandleFunc("POST /api/login", apiCfg.loginHandler)
+	muxer.HandleFunc("DELETE /api/chirps/{id}", apiCfg.deleteChirpHandler)
+	muxer.HandleFunc("GET /api/chirps/{id}/thread", apiCfg.getThreadHandler)
+	muxer.HandleFunc("POST /api/chirps/{id}/rechirp", apiCfg.rechirpHandler)
+	muxer.HandleFunc("POST /api/chirps/{id}/like", apiCfg.likeChirpHandler)
+	muxer.HandleFunc("DELETE /api/chirps/{id}/like", apiCfg.unlikeChirpHandler)
+	muxer.Handle("POST /api/login", apiCfg.middlewareRateLimit("login", loginLimit, clientIP, http.HandlerFunc(apiCfg.loginHandler)))
+	muxer.Handle("POST /api/login/mfa", apiCfg.middlewareRateLimit("login-mfa", loginLimit, clientIP, http.HandlerFunc(apiCfg.loginMFAHandler)))
+	muxer.HandleFunc("POST /api/refresh", apiCfg.refreshHandler)
+	muxer.HandleFunc("POST /api/revoke", apiCfg.revokeHandler)
+	muxer.HandleFunc("GET /api/verify", apiCfg.verifyEmailHandler)
+	muxer.Handle("POST /api/password/forgot", apiCfg.middlewareRateLimit("password-forgot", forgotLimit, clientIP, http.HandlerFunc(apiCfg.forgotPasswordHandler)))
+	muxer.Handle("POST /api/password/reset", apiCfg.middlewareRateLimit("password-reset", loginLimit, clientIP, http.HandlerFunc(apiCfg.resetPasswordHandler)))
+	muxer.Handle("POST /api/verify/resend", apiCfg.middlewareRateLimit("verify-resend", resendLimit, apiCfg.userOrIP, http.HandlerFunc(apiCfg.resendVerificationHandler)))
+	muxer.HandleFunc("POST /api/polka/webhooks", apiCfg.polkaWebhookHandler)
 	server := http.Server{
 		Handler: muxer,
 		Addr:    ":8080",
@@ -68,18 +235,13 @@ func main() {
 	}
 }
 
-func respondWithError(w http.ResponseWriter, code int, msg string) {
-	w.WriteHeader(code)
-	w.Header().Set("Content-Type", "text/plain;encoding=utf-8")
-	fmt.Fprintf(w, "%s", msg)
-}
-
 func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
-	w.WriteHeader(code)
-	w.Header().Set("Content-Type", "application/json")
 	out, ok := payload.([]byte)
 	if !ok {
-		respondWithError(w, 500, "An internal error occurred while writing the payload")
+		respondWithError(w, 500, errCodeInternal, "An internal error occurred while writing the payload")
+		return
 	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
 	w.Write(out)
 }
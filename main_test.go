@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/interyx/chirpy/internal/database"
+)
+
+// openTestDB connects to the migrated database in TEST_DB_URL, for tests
+// of behaviour that only Postgres can show, and skips without one.
+func openTestDB(t *testing.T) (*sql.DB, *database.Queries) {
+	t.Helper()
+	dbURL := os.Getenv("TEST_DB_URL")
+	if dbURL == "" {
+		t.Skip("TEST_DB_URL is not set")
+	}
+	db, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db, database.New(db)
+}
+
+// createTestUser adds a user with a random email that is deleted again
+// when the test ends.
+func createTestUser(t *testing.T, db *sql.DB, queries *database.Queries, hashedPassword string) database.User {
+	t.Helper()
+	now := time.Now()
+	user, err := queries.CreateUser(context.Background(), database.CreateUserParams{
+		ID:             uuid.New(),
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		Email:          uuid.NewString() + "@example.com",
+		HashedPassword: hashedPassword,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	t.Cleanup(func() {
+		db.ExecContext(context.Background(), "DELETE FROM users WHERE id = $1", user.ID)
+	})
+	return user
+}
+
+func TestFileHandlerHiddenDirs(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"notes.txt", "mailbox.txt", "mail/secret.eml", "media/chirps/a.png", "assets/logo.png"} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(root); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	handler := fileHandler(filepath.Join(root, "mail"), "media", filepath.Join(os.TempDir(), "elsewhere"))
+	tests := []struct {
+		path string
+		code int
+	}{
+		{"/app/notes.txt", 200},
+		{"/app/mailbox.txt", 200},
+		{"/app/assets/logo.png", 200},
+		{"/app/mail/", 404},
+		{"/app/mail", 404},
+		{"/app/mail/secret.eml", 404},
+		{"/app/MAIL/secret.eml", 404},
+		{"/app/assets/../mail/secret.eml", 404},
+		{"/app/media/", 404},
+		{"/app/media/chirps/a.png", 404},
+	}
+	for _, tc := range tests {
+		t.Run(tc.path, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "http://example.com/", nil)
+			req.URL.Path = tc.path
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tc.code {
+				t.Errorf("Expected %d, got %d", tc.code, rec.Code)
+			}
+		})
+	}
+
+	req := httptest.NewRequest("GET", "/app/", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected the listing to be served, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/interyx/chirpy/internal/database"
+	"github.com/interyx/chirpy/internal/media"
+	"github.com/interyx/chirpy/internal/storage"
+)
+
+const (
+	maxChirpImages = 4
+	maxImageSize   = 5 << 20
+	// maxChirpFormSize leaves room for the text fields and multipart
+	// framing on top of the images themselves.
+	maxChirpFormSize = maxChirpImages*maxImageSize + 1<<20
+	// mediaURLLifetime is how long the URLs in a chirp response stay valid
+	// when the store signs them.
+	mediaURLLifetime = time.Hour
+)
+
+// chirpUpload is an image that has been sniffed and stripped of metadata
+// but not yet stored.
+type chirpUpload struct {
+	data        []byte
+	contentType string
+}
+
+// mediaResponse is the public shape of an image attached to a chirp.
+type mediaResponse struct {
+	URL         string `json:"url"`
+	ContentType string `json:"content_type"`
+}
+
+// isMultipart reports whether the request body is a multipart form.
+func isMultipart(req *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	return err == nil && mediaType == "multipart/form-data"
+}
+
+// readChirpForm reads the body, in_reply_to and images fields of a
+// multipart chirp.  On failure it writes the error response and returns
+// false.
+func readChirpForm(w http.ResponseWriter, req *http.Request) (string, uuid.NullUUID, []chirpUpload, bool) {
+	req.Body = http.MaxBytesReader(w, req.Body, maxChirpFormSize)
+	err := req.ParseMultipartForm(1 << 20)
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		msg := fmt.Sprintf("Uploads are limited to %d MB in total", maxChirpFormSize>>20)
+		respondWithError(w, 413, errCodeImageTooLarge, msg)
+		return "", uuid.NullUUID{}, nil, false
+	}
+	if err != nil {
+		msg := fmt.Sprintf("An error occurred reading the form: %s", err)
+		respondWithError(w, 400, errCodeInvalidForm, msg)
+		return "", uuid.NullUUID{}, nil, false
+	}
+	defer req.MultipartForm.RemoveAll()
+
+	var inReplyTo uuid.NullUUID
+	if value := req.FormValue("in_reply_to"); value != "" {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			respondWithError(w, 400, errCodeInvalidID, "in_reply_to is not a valid UUID")
+			return "", uuid.NullUUID{}, nil, false
+		}
+		inReplyTo = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	files := req.MultipartForm.File["images"]
+	if len(files) > maxChirpImages {
+		msg := fmt.Sprintf("A chirp can have at most %d images", maxChirpImages)
+		respondWithError(w, 400, errCodeTooManyImages, msg)
+		return "", uuid.NullUUID{}, nil, false
+	}
+	uploads := make([]chirpUpload, 0, len(files))
+	for _, header := range files {
+		if header.Size > maxImageSize {
+			msg := fmt.Sprintf("%s is larger than %d MB", header.Filename, maxImageSize>>20)
+			respondWithError(w, 413, errCodeImageTooLarge, msg)
+			return "", uuid.NullUUID{}, nil, false
+		}
+		file, err := header.Open()
+		if err != nil {
+			log.Printf("An error occurred opening an upload: %s", err)
+			respondWithError(w, 500, errCodeInternal, "An error occurred reading the upload")
+			return "", uuid.NullUUID{}, nil, false
+		}
+		data, err := io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			log.Printf("An error occurred reading an upload: %s", err)
+			respondWithError(w, 500, errCodeInternal, "An error occurred reading the upload")
+			return "", uuid.NullUUID{}, nil, false
+		}
+		contentType, err := media.Sniff(data)
+		if err != nil {
+			respondWithError(w, 415, errCodeUnsupportedMedia, fmt.Sprintf("%s: %s", header.Filename, err))
+			return "", uuid.NullUUID{}, nil, false
+		}
+		data, err = media.StripMetadata(data, contentType)
+		if err != nil {
+			respondWithError(w, 400, errCodeInvalidImage, fmt.Sprintf("%s: %s", header.Filename, err))
+			return "", uuid.NullUUID{}, nil, false
+		}
+		uploads = append(uploads, chirpUpload{data: data, contentType: contentType})
+	}
+	return req.FormValue("body"), inReplyTo, uploads, true
+}
+
+// putChirpMedia stores each upload and returns the rows that record them.
+// If any upload fails the ones already stored are removed again.
+func (cfg *apiConfig) putChirpMedia(ctx context.Context, chirpID uuid.UUID, uploads []chirpUpload) ([]database.AddChirpMediaParams, error) {
+	rows := make([]database.AddChirpMediaParams, 0, len(uploads))
+	for i, upload := range uploads {
+		id := uuid.New()
+		key := fmt.Sprintf("chirps/%s/%s%s", chirpID, id, media.Extension(upload.contentType))
+		err := cfg.media.Put(ctx, key, bytes.NewReader(upload.data), upload.contentType)
+		if err != nil {
+			cfg.deleteBlobs(ctx, mediaKeys(rows))
+			return nil, err
+		}
+		rows = append(rows, database.AddChirpMediaParams{
+			ID:          id,
+			ChirpID:     chirpID,
+			Position:    int32(i),
+			StorageKey:  key,
+			ContentType: upload.contentType,
+			CreatedAt:   time.Now(),
+		})
+	}
+	return rows, nil
+}
+
+func mediaKeys(rows []database.AddChirpMediaParams) []string {
+	keys := make([]string, len(rows))
+	for i, row := range rows {
+		keys[i] = row.StorageKey
+	}
+	return keys
+}
+
+// deleteBlobs removes stored uploads.  Failures are logged rather than
+// returned since the chirp itself is already gone or was never created.
+func (cfg *apiConfig) deleteBlobs(ctx context.Context, keys []string) {
+	for _, key := range keys {
+		if err := cfg.media.Delete(ctx, key); err != nil {
+			log.Printf("An error occurred deleting %s: %s", key, err)
+		}
+	}
+}
+
+// mediaHandler serves stored uploads from the blob store, which is how
+// the URLs handed out by the filesystem backend are fetched.  There are
+// no directory listings here, and /app/ refuses the storage directory, so
+// keys cannot be enumerated.
+func (cfg *apiConfig) mediaHandler(w http.ResponseWriter, req *http.Request) {
+	key := strings.TrimPrefix(req.URL.Path, "/media/")
+	blob, contentType, err := cfg.media.Get(req.Context(), key)
+	if errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrInvalidKey) {
+		http.NotFound(w, req)
+		return
+	}
+	if err != nil {
+		log.Printf("An error occurred reading %s: %s", key, err)
+		http.Error(w, "An error occurred reading the file", 500)
+		return
+	}
+	defer blob.Close()
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	if _, err := io.Copy(w, blob); err != nil {
+		// The 200 is already on the wire, so breaking the connection is
+		// the only way left to tell the client the body is incomplete.
+		log.Printf("An error occurred sending %s: %s", key, err)
+		panic(http.ErrAbortHandler)
+	}
+}
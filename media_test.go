@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/interyx/chirpy/internal/storage"
+)
+
+// failingStore serves blobs that break off after a few bytes, like an S3
+// read that fails halfway.
+type failingStore struct {
+	storage.BlobStore
+}
+
+func (failingStore) Get(ctx context.Context, key string) (io.ReadCloser, string, error) {
+	// Enough bytes that the status line and headers have been flushed.
+	partial := strings.NewReader(strings.Repeat("x", 64<<10))
+	body := io.MultiReader(partial, iotest.ErrReader(errors.New("connection reset")))
+	return io.NopCloser(body), "image/png", nil
+}
+
+func TestMediaHandlerTruncatedRead(t *testing.T) {
+	cfg := &apiConfig{media: failingStore{}}
+	server := httptest.NewServer(http.HandlerFunc(cfg.mediaHandler))
+	defer server.Close()
+	resp, err := server.Client().Get(server.URL + "/media/chirps/a.png")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		t.Fatalf("Expected the 200 to have been sent, got %d", resp.StatusCode)
+	}
+	if _, err := io.ReadAll(resp.Body); err == nil {
+		t.Errorf("Expected reading a truncated blob to fail, it looked complete")
+	}
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/interyx/chirpy/internal/auth"
+	"github.com/interyx/chirpy/internal/database"
+)
+
+const (
+	// mfaChallengeLifetime is how long a client has to send the code after
+	// a correct password.
+	mfaChallengeLifetime = 5 * time.Minute
+	recoveryCodeCount    = 10
+	totpIssuer           = "Chirpy"
+)
+
+// respondMFARequired answers a correct password on an account with two
+// factors turned on: no tokens yet, only a challenge for POST
+// /api/login/mfa.
+func (cfg *apiConfig) respondMFARequired(w http.ResponseWriter, user database.User) {
+	type outerface struct {
+		MFARequired bool   `json:"mfa_required"`
+		MFAToken    string `json:"mfa_token"`
+	}
+	token, err := auth.MakeMFAToken(user.ID, cfg.signJWT, mfaChallengeLifetime)
+	if err != nil {
+		respondWithError(w, 500, errCodeInternal, "Could not create the MFA challenge")
+		return
+	}
+	out, err := json.Marshal(outerface{MFARequired: true, MFAToken: token})
+	if err != nil {
+		respondWithError(w, 500, errCodeInternal, "A marshaling error occurred")
+		return
+	}
+	respondWithJSON(w, 200, out)
+}
+
+// checkMFACode accepts either a current TOTP code or an unused recovery
+// code, and spends it so that it cannot be used again.
+func (cfg *apiConfig) checkMFACode(ctx context.Context, user database.User, code string, now time.Time) (bool, error) {
+	step, err := auth.ValidateTOTP(user.TotpSecret, code, now)
+	if err == nil {
+		// Only a step newer than the last accepted one counts, so a code
+		// seen over someone's shoulder is already spent.
+		rows, err := cfg.db.UseTOTPStep(ctx, database.UseTOTPStepParams{
+			ID:           user.ID,
+			TotpLastStep: step,
+		})
+		return rows == 1, err
+	}
+	if !errors.Is(err, auth.ErrInvalidTOTPCode) {
+		return false, err
+	}
+	rows, err := cfg.db.UseRecoveryCode(ctx, database.UseRecoveryCodeParams{
+		UsedAt:   sql.NullTime{Time: now, Valid: true},
+		UserID:   user.ID,
+		CodeHash: auth.HashRecoveryCode(code),
+	})
+	return rows == 1, err
+}
+
+func (cfg *apiConfig) loginMFAHandler(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		MFAToken         string `json:"mfa_token"`
+		Code             string `json:"code"`
+		ExpiresInSeconds int    `json:"expires_in_seconds"`
+	}
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	if err := decoder.Decode(&params); err != nil {
+		msg := fmt.Sprintf("An error occurred marshaling JSON: %s", err)
+		respondWithError(w, 400, errCodeInvalidJSON, msg)
+		return
+	}
+	if params.MFAToken == "" || params.Code == "" {
+		respondWithError(w, 400, errCodeMissingFields, "An MFA token and a code are required")
+		return
+	}
+	ip := clientIP(req)
+	now := time.Now()
+	if wait, _ := cfg.ipLogins.Check(ip, now); wait > 0 {
+		respondLoginWait(w, wait, false)
+		return
+	}
+	userID, err := auth.ValidateMFAToken(params.MFAToken, cfg.signJWT)
+	if err != nil {
+		respondWithError(w, 401, errCodeInvalidMFAToken, "The MFA token is invalid or has expired")
+		return
+	}
+	user, err := cfg.db.GetUserByID(req.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 401, errCodeInvalidMFAToken, "The MFA token is invalid or has expired")
+		return
+	}
+	if err != nil {
+		log.Printf("An error occurred retrieving the user: %s", err)
+		respondWithError(w, 500, errCodeInternal, "An error occurred retrieving the user")
+		return
+	}
+	// Two factors may have been turned off since the challenge was issued.
+	if !user.TotpEnabled {
+		respondWithError(w, 401, errCodeInvalidMFAToken, "The MFA token is invalid or has expired")
+		return
+	}
+	if !cfg.checkLogin(w, user.Email, user, true, now) {
+		return
+	}
+	ok, err := cfg.checkMFACode(req.Context(), user, params.Code, now)
+	if err != nil {
+		log.Printf("An error occurred checking the MFA code: %s", err)
+		respondWithError(w, 500, errCodeInternal, "An error occurred checking the code")
+		return
+	}
+	if !ok {
+		if err := cfg.recordLoginFailure(req.Context(), ip, user.Email, user, true, now); err != nil {
+			log.Printf("An error occurred recording a failed login: %s", err)
+		}
+		respondWithError(w, 401, errCodeInvalidMFACode, "Incorrect or already used code")
+		return
+	}
+	cfg.completeLogin(w, req, user, params.ExpiresInSeconds)
+}
+
+// enrollMFAHandler starts enrollment with a new secret.  Two factors are
+// not required until a code from it is confirmed, so an abandoned
+// enrollment locks nobody out.
+func (cfg *apiConfig) enrollMFAHandler(w http.ResponseWriter, req *http.Request) {
+	type outerface struct {
+		Secret     string `json:"secret"`
+		OTPAuthURI string `json:"otpauth_uri"`
+	}
+	userID, ok := cfg.authenticate(w, req)
+	if !ok {
+		return
+	}
+	user, err := cfg.db.GetUserByID(req.Context(), userID)
+	if err != nil {
+		log.Printf("An error occurred retrieving the user: %s", err)
+		respondWithError(w, 500, errCodeInternal, "An error occurred retrieving the user")
+		return
+	}
+	if user.TotpEnabled {
+		respondWithError(w, 409, errCodeMFAEnabled, "Two-factor authentication is already on")
+		return
+	}
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		respondWithError(w, 500, errCodeInternal, "Could not create a secret")
+		return
+	}
+	err = cfg.db.SetTOTPSecret(req.Context(), database.SetTOTPSecretParams{
+		ID:         userID,
+		TotpSecret: secret,
+		UpdatedAt:  time.Now(),
+	})
+	if err != nil {
+		log.Printf("An error occurred saving the TOTP secret: %s", err)
+		respondWithError(w, 500, errCodeInternal, "An error occurred saving the secret")
+		return
+	}
+	out, err := json.Marshal(outerface{
+		Secret:     secret,
+		OTPAuthURI: auth.TOTPURI(secret, totpIssuer, user.Email),
+	})
+	if err != nil {
+		respondWithError(w, 500, errCodeInternal, "A marshaling error occurred")
+		return
+	}
+	respondWithJSON(w, 200, out)
+}
+
+// confirmMFAHandler turns two factors on once the user proves their app
+// produces the right codes, and hands out the recovery codes.  This is
+// the only time the recovery codes are shown.
+func (cfg *apiConfig) confirmMFAHandler(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Code string `json:"code"`
+	}
+	type outerface struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	userID, ok := cfg.authenticate(w, req)
+	if !ok {
+		return
+	}
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	if err := decoder.Decode(&params); err != nil {
+		msg := fmt.Sprintf("An error occurred marshaling JSON: %s", err)
+		respondWithError(w, 400, errCodeInvalidJSON, msg)
+		return
+	}
+	if params.Code == "" {
+		respondWithError(w, 400, errCodeMissingFields, "A code is required")
+		return
+	}
+	user, err := cfg.db.GetUserByID(req.Context(), userID)
+	if err != nil {
+		log.Printf("An error occurred retrieving the user: %s", err)
+		respondWithError(w, 500, errCodeInternal, "An error occurred retrieving the user")
+		return
+	}
+	if user.TotpEnabled {
+		respondWithError(w, 409, errCodeMFAEnabled, "Two-factor authentication is already on")
+		return
+	}
+	if user.TotpSecret == "" {
+		respondWithError(w, 409, errCodeMFANotEnrolled, "Start enrollment before confirming a code")
+		return
+	}
+	now := time.Now()
+	step, err := auth.ValidateTOTP(user.TotpSecret, params.Code, now)
+	if err != nil {
+		respondWithError(w, 401, errCodeInvalidMFACode, "Incorrect code")
+		return
+	}
+	codes, err := auth.MakeRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		respondWithError(w, 500, errCodeInternal, "Could not create recovery codes")
+		return
+	}
+
+	tx, err := cfg.sqlDB.BeginTx(req.Context(), nil)
+	if err != nil {
+		log.Printf("An error occurred starting a transaction: %s", err)
+		respondWithError(w, 500, errCodeInternal, "An error occurred enabling two-factor authentication")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+	err = qtx.EnableTOTP(req.Context(), database.EnableTOTPParams{
+		ID:           userID,
+		TotpLastStep: step,
+		UpdatedAt:    now,
+	})
+	if err == nil {
+		err = qtx.DeleteRecoveryCodes(req.Context(), userID)
+	}
+	for _, code := range codes {
+		if err != nil {
+			break
+		}
+		err = qtx.CreateRecoveryCode(req.Context(), database.CreateRecoveryCodeParams{
+			UserID:    userID,
+			CodeHash:  auth.HashRecoveryCode(code),
+			CreatedAt: now,
+		})
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Printf("An error occurred enabling two-factor authentication: %s", err)
+		respondWithError(w, 500, errCodeInternal, "An error occurred enabling two-factor authentication")
+		return
+	}
+	out, err := json.Marshal(outerface{RecoveryCodes: codes})
+	if err != nil {
+		respondWithError(w, 500, errCodeInternal, "A marshaling error occurred")
+		return
+	}
+	respondWithJSON(w, 200, out)
+}
+
+// disableMFAHandler turns two factors off.  It asks for a code as well as
+// the access token, so a stolen session alone cannot remove the second
+// factor.  Wrong codes count as failed logins, so the code cannot be
+// guessed here any faster than at POST /api/login/mfa.
+func (cfg *apiConfig) disableMFAHandler(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Code string `json:"code"`
+	}
+	userID, ok := cfg.authenticate(w, req)
+	if !ok {
+		return
+	}
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	if err := decoder.Decode(&params); err != nil {
+		msg := fmt.Sprintf("An error occurred marshaling JSON: %s", err)
+		respondWithError(w, 400, errCodeInvalidJSON, msg)
+		return
+	}
+	if params.Code == "" {
+		respondWithError(w, 400, errCodeMissingFields, "A code is required")
+		return
+	}
+	user, err := cfg.db.GetUserByID(req.Context(), userID)
+	if err != nil {
+		log.Printf("An error occurred retrieving the user: %s", err)
+		respondWithError(w, 500, errCodeInternal, "An error occurred retrieving the user")
+		return
+	}
+	if !user.TotpEnabled {
+		respondWithError(w, 409, errCodeMFANotEnrolled, "Two-factor authentication is not on")
+		return
+	}
+	ip := clientIP(req)
+	now := time.Now()
+	if wait, _ := cfg.ipLogins.Check(ip, now); wait > 0 {
+		respondLoginWait(w, wait, false)
+		return
+	}
+	if !cfg.checkLogin(w, user.Email, user, true, now) {
+		return
+	}
+	ok, err = cfg.checkMFACode(req.Context(), user, params.Code, now)
+	if err != nil {
+		log.Printf("An error occurred checking the MFA code: %s", err)
+		respondWithError(w, 500, errCodeInternal, "An error occurred checking the code")
+		return
+	}
+	if !ok {
+		if err := cfg.recordLoginFailure(req.Context(), ip, user.Email, user, true, now); err != nil {
+			log.Printf("An error occurred recording a failed login: %s", err)
+		}
+		respondWithError(w, 401, errCodeInvalidMFACode, "Incorrect or already used code")
+		return
+	}
+
+	tx, err := cfg.sqlDB.BeginTx(req.Context(), nil)
+	if err != nil {
+		log.Printf("An error occurred starting a transaction: %s", err)
+		respondWithError(w, 500, errCodeInternal, "An error occurred disabling two-factor authentication")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+	err = qtx.DisableTOTP(req.Context(), database.DisableTOTPParams{
+		ID:        userID,
+		UpdatedAt: now,
+	})
+	if err == nil {
+		err = qtx.DeleteRecoveryCodes(req.Context(), userID)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Printf("An error occurred disabling two-factor authentication: %s", err)
+		respondWithError(w, 500, errCodeInternal, "An error occurred disabling two-factor authentication")
+		return
+	}
+	w.WriteHeader(204)
+}
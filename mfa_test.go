@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/interyx/chirpy/internal/auth"
+	"github.com/interyx/chirpy/internal/database"
+	"github.com/interyx/chirpy/internal/lockout"
+)
+
+func TestLoginMFARejectsOtherTokens(t *testing.T) {
+	cfg := &apiConfig{
+		signJWT:       testSecret,
+		accountLogins: lockout.NewTracker(lockout.DefaultPolicy),
+		ipLogins:      lockout.NewTracker(ipLoginPolicy),
+	}
+	access, err := auth.MakeJWT(uuid.New(), testSecret, time.Hour)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expired, err := auth.MakeMFAToken(uuid.New(), testSecret, -time.Minute)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for name, token := range map[string]string{"Access token": access, "Expired challenge": expired} {
+		t.Run(name, func(t *testing.T) {
+			body := `{"mfa_token": "` + token + `", "code": "123456"}`
+			req := httptest.NewRequest("POST", "/api/login/mfa", strings.NewReader(body))
+			rec := httptest.NewRecorder()
+			cfg.loginMFAHandler(rec, req)
+			if rec.Code != 401 {
+				t.Fatalf("Expected status 401, got %d", rec.Code)
+			}
+			if got := decodeError(t, rec); got.Code != errCodeInvalidMFAToken {
+				t.Errorf("Expected code %s, got %s", errCodeInvalidMFAToken, got.Code)
+			}
+		})
+	}
+}
+
+func TestDisableMFACountsFailures(t *testing.T) {
+	db, queries := openTestDB(t)
+	ctx := context.Background()
+	user := createTestUser(t, db, queries, "unused")
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	now := time.Now()
+	err = queries.SetTOTPSecret(ctx, database.SetTOTPSecretParams{ID: user.ID, TotpSecret: secret, UpdatedAt: now})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	err = queries.EnableTOTP(ctx, database.EnableTOTPParams{ID: user.ID, UpdatedAt: now})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	policy := lockout.Policy{FreeAttempts: 1, BaseDelay: time.Minute, MaxDelay: time.Hour, ResetAfter: time.Hour}
+	cfg := &apiConfig{
+		db:            queries,
+		signJWT:       testSecret,
+		accountLogins: lockout.NewTracker(policy),
+		ipLogins:      lockout.NewTracker(ipLoginPolicy),
+	}
+	token, err := auth.MakeJWT(user.ID, testSecret, time.Hour)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	code, err := auth.TOTPCode(secret, now.Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// The first wrong code is free; after it the account has to wait, so
+	// even the right code is refused.
+	want := []int{401, 429}
+	for i, status := range want {
+		req := httptest.NewRequest("DELETE", "/api/users/mfa", strings.NewReader(`{"code": "`+code+`"}`))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		cfg.disableMFAHandler(rec, req)
+		if rec.Code != status {
+			t.Fatalf("Attempt %d: expected status %d, got %d", i, status, rec.Code)
+		}
+		code, _ = auth.TOTPCode(secret, time.Now())
+	}
+	user, err = queries.GetUserByID(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if user.FailedLogins != 1 {
+		t.Errorf("Expected 1 failed login, got %d", user.FailedLogins)
+	}
+	if !user.TotpEnabled {
+		t.Errorf("Expected two-factor authentication to still be on")
+	}
+}
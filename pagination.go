@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/interyx/chirpy/internal/database"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// pageCursor marks the last row of a page in keyset order.  Clients only
+// ever see it as an opaque string.
+type pageCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// firstPageCursor returns a cursor that sorts before (or after, when
+// descending) every row so the first page can share the keyset queries.
+func firstPageCursor(descending bool) pageCursor {
+	if descending {
+		return pageCursor{
+			CreatedAt: time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC),
+			ID:        uuid.Max,
+		}
+	}
+	return pageCursor{
+		CreatedAt: time.Date(1, time.January, 1, 0, 0, 0, 0, time.UTC),
+		ID:        uuid.Nil,
+	}
+}
+
+func (c pageCursor) String() string {
+	raw := c.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + c.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func parseCursor(s string) (pageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return pageCursor{}, fmt.Errorf("malformed cursor: %w", err)
+	}
+	createdAt, id, found := strings.Cut(string(raw), "|")
+	if !found {
+		return pageCursor{}, fmt.Errorf("malformed cursor")
+	}
+	t, err := time.Parse(time.RFC3339Nano, createdAt)
+	if err != nil {
+		return pageCursor{}, fmt.Errorf("malformed cursor: %w", err)
+	}
+	u, err := uuid.Parse(id)
+	if err != nil {
+		return pageCursor{}, fmt.Errorf("malformed cursor: %w", err)
+	}
+	return pageCursor{CreatedAt: t, ID: u}, nil
+}
+
+// pageParams reads the limit and cursor query parameters.
+func pageParams(req *http.Request, descending bool) (int32, pageCursor, error) {
+	limit := defaultPageSize
+	if param := req.URL.Query().Get("limit"); param != "" {
+		n, err := strconv.Atoi(param)
+		if err != nil || n < 1 || n > maxPageSize {
+			return 0, pageCursor{}, fmt.Errorf("limit must be between 1 and %d", maxPageSize)
+		}
+		limit = n
+	}
+	cursor := firstPageCursor(descending)
+	if param := req.URL.Query().Get("cursor"); param != "" {
+		c, err := parseCursor(param)
+		if err != nil {
+			return 0, pageCursor{}, err
+		}
+		cursor = c
+	}
+	return int32(limit), cursor, nil
+}
+
+// offsetPageParams reads the limit and offset query parameters for
+// listings, like ranked search results, that have no stable keyset order.
+func offsetPageParams(req *http.Request) (int32, int32, error) {
+	limit := defaultPageSize
+	if param := req.URL.Query().Get("limit"); param != "" {
+		n, err := strconv.Atoi(param)
+		if err != nil || n < 1 || n > maxPageSize {
+			return 0, 0, fmt.Errorf("limit must be between 1 and %d", maxPageSize)
+		}
+		limit = n
+	}
+	offset := 0
+	if param := req.URL.Query().Get("offset"); param != "" {
+		n, err := strconv.Atoi(param)
+		if err != nil || n < 0 {
+			return 0, 0, fmt.Errorf("offset must be a non-negative integer")
+		}
+		offset = n
+	}
+	return int32(limit), int32(offset), nil
+}
+
+// setNextLink advertises the next page through a Link header, keeping
+// every other query parameter of the current request.
+func setNextLink(w http.ResponseWriter, req *http.Request, next pageCursor) {
+	setNextLinkParam(w, req, "cursor", next.String())
+}
+
+func setNextLinkParam(w http.ResponseWriter, req *http.Request, key, value string) {
+	query := req.URL.Query()
+	query.Set(key, value)
+	link := fmt.Sprintf("<%s?%s>; rel=\"next\"", req.URL.Path, query.Encode())
+	w.Header().Set("Link", link)
+}
+
+// trimChirpPage drops the extra row a page is fetched with and, when it
+// was there, points the Link header at the page after it.
+func trimChirpPage(w http.ResponseWriter, req *http.Request, chirps []database.Chirp, limit int32) []database.Chirp {
+	if len(chirps) <= int(limit) {
+		return chirps
+	}
+	chirps = chirps[:limit]
+	last := chirps[len(chirps)-1]
+	setNextLink(w, req, pageCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	return chirps
+}
+
+// writeChirpPage trims a page fetched with one extra row, sets the Link
+// header when more rows follow and writes the decorated chirps.
+func (cfg *apiConfig) writeChirpPage(w http.ResponseWriter, req *http.Request, chirps []database.Chirp, limit int32, viewer uuid.UUID, hasViewer bool) {
+	chirps = trimChirpPage(w, req, chirps, limit)
+	resp, err := cfg.chirpResponses(req.Context(), chirps, viewer, hasViewer)
+	if err != nil {
+		log.Printf("An error occurred getting chirp counts: %s", err)
+		respondWithError(w, 500, errCodeInternal, "An error occurred retrieving chirps")
+		return
+	}
+	out, err := json.Marshal(resp)
+	if err != nil {
+		log.Printf("An error occurred marshalling JSON: %s", err)
+		respondWithError(w, 500, errCodeInternal, "A marshaling error occurred")
+		return
+	}
+	respondWithJSON(w, 200, out)
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/interyx/chirpy/internal/auth"
+	"github.com/interyx/chirpy/internal/database"
+	"github.com/interyx/chirpy/internal/mailer"
+)
+
+const (
+	passwordResetLifetime = time.Hour
+	// passwordResetTimeout bounds the background work of a forgot request,
+	// which outlives the request itself.
+	passwordResetTimeout = 30 * time.Second
+)
+
+func (cfg *apiConfig) forgotPasswordHandler(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Email string `json:"email"`
+	}
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	if err := decoder.Decode(&params); err != nil {
+		msg := fmt.Sprintf("An error occurred marshaling JSON: %s", err)
+		respondWithError(w, 400, errCodeInvalidJSON, msg)
+		return
+	}
+	if params.Email == "" {
+		respondWithError(w, 400, errCodeMissingFields, "An email is required")
+		return
+	}
+	// The lookup and the email happen after the response, so neither the
+	// answer nor how long it takes says whether the account exists.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(req.Context()), passwordResetTimeout)
+	go func() {
+		defer cancel()
+		if err := cfg.sendPasswordReset(ctx, params.Email); err != nil {
+			log.Printf("An error occurred sending a password reset: %s", err)
+		}
+	}()
+	w.WriteHeader(202)
+}
+
+// sendPasswordReset mails a reset token to the account with this email,
+// if there is one.  Only the token's hash is stored, and tokens sent
+// earlier stop working.
+func (cfg *apiConfig) sendPasswordReset(ctx context.Context, email string) error {
+	user, err := cfg.db.GetUserByEmail(ctx, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	err = cfg.db.DeletePasswordResets(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	err = cfg.db.CreatePasswordReset(ctx, database.CreatePasswordResetParams{
+		TokenHash: auth.HashToken(token),
+		UserID:    user.ID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(passwordResetLifetime),
+	})
+	if err != nil {
+		return err
+	}
+	return cfg.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your Chirpy password",
+		Body: fmt.Sprintf("Someone asked to reset the password for this Chirpy account.\n\n"+
+			"Send this token to %s/api/password/reset within %d minutes to choose a new password:\n\n%s\n\n"+
+			"If it was not you, you can ignore this message and your password will stay the same.\n",
+			cfg.publicURL, int(passwordResetLifetime.Minutes()), token),
+	})
+}
+
+func (cfg *apiConfig) resetPasswordHandler(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	if err := decoder.Decode(&params); err != nil {
+		msg := fmt.Sprintf("An error occurred marshaling JSON: %s", err)
+		respondWithError(w, 400, errCodeInvalidJSON, msg)
+		return
+	}
+	if params.Token == "" || params.Password == "" {
+		respondWithError(w, 400, errCodeMissingFields, "A token and a new password are required")
+		return
+	}
+	hashedPassword, err := auth.HashPassword(params.Password)
+	if err != nil {
+		msg := fmt.Sprintf("An error occurred generating a password: %s", err)
+		respondWithError(w, 500, errCodeInternal, msg)
+		return
+	}
+
+	tx, err := cfg.sqlDB.BeginTx(req.Context(), nil)
+	if err != nil {
+		log.Printf("An error occurred starting a transaction: %s", err)
+		respondWithError(w, 500, errCodeInternal, "An error occurred resetting the password")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	now := time.Now()
+	userID, err := qtx.UsePasswordReset(req.Context(), database.UsePasswordResetParams{
+		Now:       now,
+		TokenHash: auth.HashToken(params.Token),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 400, errCodeInvalidResetToken, "The reset token is invalid or has expired")
+		return
+	}
+	if err == nil {
+		err = qtx.SetUserPassword(req.Context(), database.SetUserPasswordParams{
+			ID:             userID,
+			HashedPassword: hashedPassword,
+			UpdatedAt:      now,
+		})
+	}
+	// Whoever held the old password is signed out everywhere.
+	if err == nil {
+		err = qtx.RevokeUserTokens(req.Context(), database.RevokeUserTokensParams{
+			UpdatedAt: now,
+			RevokedAt: sql.NullTime{Time: now, Valid: true},
+			UserID:    userID,
+		})
+	}
+	// Proving control of the email is enough to lift a lockout.
+	if err == nil {
+		err = qtx.ResetLoginFailures(req.Context(), userID)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Printf("An error occurred resetting the password: %s", err)
+		respondWithError(w, 500, errCodeInternal, "An error occurred resetting the password")
+		return
+	}
+	w.WriteHeader(204)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/interyx/chirpy/internal/database"
+	"github.com/interyx/chirpy/internal/profanity"
+)
+
+// reloadBannedWords rebuilds the filter from the word file read at startup
+// plus the banned_words table.
+func (cfg *apiConfig) reloadBannedWords(ctx context.Context) error {
+	dbWords, err := cfg.db.GetBannedWords(ctx)
+	if err != nil {
+		return err
+	}
+	words := make([]string, 0, len(cfg.profanityWords)+len(dbWords))
+	words = append(words, cfg.profanityWords...)
+	words = append(words, dbWords...)
+	cfg.profanity.SetWords(words)
+	return nil
+}
+
+// moderateChirp applies the configured profanity mode to a chirp body.  It
+// returns the body to store and the banned words found.  In reject mode
+// the caller should refuse the chirp whenever matches is non-empty.
+func (cfg *apiConfig) moderateChirp(body string) (string, []string) {
+	result := cfg.profanity.Check(body)
+	if cfg.profanityMode == profanity.ModeMask {
+		return result.Masked, result.Matches
+	}
+	return body, result.Matches
+}
+
+// flagChirp records a chirp for review when running in flag mode.
+func (cfg *apiConfig) flagChirp(ctx context.Context, q *database.Queries, chirpID uuid.UUID, matches []string) error {
+	if cfg.profanityMode != profanity.ModeFlag || len(matches) == 0 {
+		return nil
+	}
+	flagParams := database.FlagChirpParams{
+		ChirpID:   chirpID,
+		CreatedAt: time.Now(),
+		Words:     strings.Join(matches, ","),
+	}
+	return q.FlagChirp(ctx, flagParams)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/interyx/chirpy/internal/auth"
+	"github.com/interyx/chirpy/internal/ratelimit"
+)
+
+// middlewareRateLimit draws a token from the bucket that key picks for
+// each request, and answers 429 once the bucket is empty.  Buckets are
+// namespaced by name so each route keeps its own count.
+func (cfg *apiConfig) middlewareRateLimit(name string, limit ratelimit.Limit, key func(*http.Request) string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result, err := cfg.rateLimiter.Take(r.Context(), name+":"+key(r), limit, time.Now())
+		if err != nil {
+			// A broken limiter should not take the API down with it.
+			log.Printf("An error occurred checking the rate limit: %s", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		w.Header().Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+		if !result.Allowed {
+			retryAfter := max(ceilSeconds(result.RetryAfter), 1)
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			msg := fmt.Sprintf("Too many requests, try again in %d seconds", retryAfter)
+			respondWithError(w, 429, errCodeRateLimited, msg)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// clientIP keys a bucket by the address the request came from.
+func clientIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return "ip:" + req.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// userOrIP keys a bucket by the authenticated user, falling back to the
+// address for requests without a valid token, which the handler rejects.
+func (cfg *apiConfig) userOrIP(req *http.Request) string {
+	token, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		return clientIP(req)
+	}
+	userID, err := auth.ValidateJWT(token, cfg.signJWT)
+	if err != nil {
+		return clientIP(req)
+	}
+	return "user:" + userID.String()
+}
+
+// rateLimitFromEnv reads a limit such as "10/m" from the environment.
+func rateLimitFromEnv(name, fallback string) ratelimit.Limit {
+	value := os.Getenv(name)
+	if value == "" {
+		value = fallback
+	}
+	limit, err := ratelimit.ParseLimit(value)
+	if err != nil {
+		fmt.Printf("%s, falling back to %s\n", err, fallback)
+		limit, _ = ratelimit.ParseLimit(fallback)
+	}
+	return limit
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/interyx/chirpy/internal/auth"
+	"github.com/interyx/chirpy/internal/ratelimit"
+)
+
+func TestMiddlewareRateLimit(t *testing.T) {
+	cfg := &apiConfig{signJWT: testSecret, rateLimiter: ratelimit.NewMemory()}
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(204)
+	})
+	limit := ratelimit.Limit{Rate: 1.0 / 60, Burst: 2}
+	handler := cfg.middlewareRateLimit("test", limit, cfg.userOrIP, ok)
+
+	send := func(remoteAddr, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/chirps", nil)
+		req.RemoteAddr = remoteAddr
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for i, want := range []string{"1", "0"} {
+		rec := send("192.0.2.1:1234", "")
+		if rec.Code != 204 {
+			t.Fatalf("Request %d: expected status 204, got %d", i, rec.Code)
+		}
+		if got := rec.Header().Get("X-RateLimit-Remaining"); got != want {
+			t.Errorf("Request %d: expected X-RateLimit-Remaining %s, got %s", i, want, got)
+		}
+		if got := rec.Header().Get("X-RateLimit-Limit"); got != "2" {
+			t.Errorf("Request %d: expected X-RateLimit-Limit 2, got %s", i, got)
+		}
+	}
+
+	// A different port on the same address shares the bucket.
+	rec := send("192.0.2.1:5678", "")
+	if rec.Code != 429 {
+		t.Fatalf("Expected status 429, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "60" {
+		t.Errorf("Expected Retry-After 60, got %q", got)
+	}
+	if got := decodeError(t, rec); got.Code != errCodeRateLimited {
+		t.Errorf("Expected code %s, got %s", errCodeRateLimited, got.Code)
+	}
+
+	// An authenticated user from the same address has a bucket of their own.
+	token, err := auth.MakeJWT(uuid.New(), testSecret, time.Hour)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if rec := send("192.0.2.1:1234", token); rec.Code != 204 {
+		t.Errorf("Expected status 204 for an authenticated user, got %d", rec.Code)
+	}
+}
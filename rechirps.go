@@ -0,0 +1,113 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/interyx/chirpy/internal/database"
+	"github.com/lib/pq"
+)
+
+// repostOriginal is the chirp a repost points at.  Once the original is
+// deleted only the tombstone flag is left.
+type repostOriginal struct {
+	*chirpResponse
+	Deleted bool `json:"deleted,omitempty"`
+}
+
+// duplicateRechirp reports whether err is the unique index on plain
+// rechirps refusing a second one of the same chirp.
+func duplicateRechirp(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "chirps_plain_rechirp_idx"
+}
+
+func (cfg *apiConfig) rechirpHandler(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Body string `json:"body"`
+	}
+	userID, ok := cfg.authenticate(w, req)
+	if !ok {
+		return
+	}
+	id, err := uuid.Parse(req.PathValue("id"))
+	if err != nil {
+		respondWithError(w, 400, errCodeInvalidID, "Chirp ID is not a valid UUID")
+		return
+	}
+	// The body is optional: no body at all is a plain repost.
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	if err := decoder.Decode(&params); err != nil && !errors.Is(err, io.EOF) {
+		msg := fmt.Sprintf("An error occurred marshaling JSON: %s", err)
+		respondWithError(w, 400, errCodeInvalidJSON, msg)
+		return
+	}
+	body, matches, ok := cfg.prepareChirpBody(w, params.Body)
+	if !ok {
+		return
+	}
+
+	if !cfg.requireVerified(w, req, userID) {
+		return
+	}
+
+	original, err := cfg.db.GetChirp(req.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 404, errCodeChirpNotFound, "Chirp not found")
+		return
+	}
+	if err != nil {
+		log.Printf("An error occurred retrieving the record: %s", err)
+		respondWithError(w, 500, errCodeInternal, "An error occurred retrieving the chirp")
+		return
+	}
+	// Reposting a plain repost reposts what it points at instead.
+	if original.IsRepost && original.Body == "" {
+		if !original.RepostOf.Valid {
+			respondWithError(w, 404, errCodeChirpNotFound, "The original chirp has been deleted")
+			return
+		}
+		id = original.RepostOf.UUID
+	}
+
+	chirpParams := database.CreateChirpParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		Body:      body,
+		UserID:    userID,
+		RepostOf:  uuid.NullUUID{UUID: id, Valid: true},
+		IsRepost:  true,
+	}
+	newChirp, err := cfg.storeChirp(req.Context(), chirpParams, matches, nil)
+	if duplicateRechirp(err) {
+		respondWithError(w, 409, errCodeAlreadyRechirped, "You have already rechirped that chirp")
+		return
+	}
+	if err != nil {
+		log.Printf("An error occurred while creating the rechirp: %s\n", err)
+		respondWithError(w, 500, errCodeInternal, "An error occurred creating the rechirp")
+		return
+	}
+	resp, err := cfg.chirpResponses(req.Context(), []database.Chirp{newChirp}, userID, true)
+	if err != nil {
+		log.Printf("An error occurred loading the original chirp: %s", err)
+		respondWithError(w, 500, errCodeInternal, "An error occurred loading the original chirp")
+		return
+	}
+	out, err := json.Marshal(resp[0])
+	if err != nil {
+		log.Printf("An error occurred marshaling JSON data: %s", err)
+		respondWithError(w, 500, errCodeInternal, "A marshaling error occurred")
+		return
+	}
+	respondWithJSON(w, 201, out)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/interyx/chirpy/internal/database"
+)
+
+// searchResult is a chirp with its rank and an HTML-escaped copy of the
+// body where the matched terms are wrapped in <mark> tags.
+type searchResult struct {
+	chirpResponse
+	Rank      float32 `json:"rank"`
+	Highlight string  `json:"highlight"`
+}
+
+// escapeHeadline escapes a ts_headline result so it is safe to render,
+// keeping only the <mark> tags Postgres added.
+func escapeHeadline(headline string) string {
+	escaped := html.EscapeString(headline)
+	escaped = strings.ReplaceAll(escaped, "&lt;mark&gt;", "<mark>")
+	return strings.ReplaceAll(escaped, "&lt;/mark&gt;", "</mark>")
+}
+
+// parseSearchTime accepts either a full RFC 3339 timestamp or a bare date,
+// which is taken as midnight UTC.  The result is in UTC because the query
+// casts it to a TIMESTAMP, which drops the offset.
+func parseSearchTime(value string) (sql.NullTime, error) {
+	if value == "" {
+		return sql.NullTime{}, nil
+	}
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		t, err := time.Parse(layout, value)
+		if err == nil {
+			return sql.NullTime{Time: t.UTC(), Valid: true}, nil
+		}
+	}
+	return sql.NullTime{}, fmt.Errorf("%q is not an RFC 3339 timestamp or a YYYY-MM-DD date", value)
+}
+
+func (cfg *apiConfig) searchChirpsHandler(w http.ResponseWriter, req *http.Request) {
+	viewer, hasViewer, ok := cfg.optionalViewer(w, req)
+	if !ok {
+		return
+	}
+	query := strings.TrimSpace(req.URL.Query().Get("q"))
+	if query == "" {
+		respondWithError(w, 400, errCodeMissingFields, "A search query is required")
+		return
+	}
+	limit, offset, err := offsetPageParams(req)
+	if err != nil {
+		respondWithError(w, 400, errCodeInvalidPagination, err.Error())
+		return
+	}
+	searchParams := database.SearchChirpsParams{
+		Query:      query,
+		PageSize:   limit + 1,
+		PageOffset: offset,
+	}
+	if authorParam := req.URL.Query().Get("author_id"); authorParam != "" {
+		authorID, err := uuid.Parse(authorParam)
+		if err != nil {
+			respondWithError(w, 400, errCodeInvalidID, "author_id is not a valid UUID")
+			return
+		}
+		searchParams.AuthorID = uuid.NullUUID{UUID: authorID, Valid: true}
+	}
+	searchParams.Since, err = parseSearchTime(req.URL.Query().Get("since"))
+	if err != nil {
+		respondWithError(w, 400, errCodeInvalidDate, err.Error())
+		return
+	}
+	searchParams.Until, err = parseSearchTime(req.URL.Query().Get("until"))
+	if err != nil {
+		respondWithError(w, 400, errCodeInvalidDate, err.Error())
+		return
+	}
+
+	// Ask for one extra row to learn whether another page follows.
+	rows, err := cfg.db.SearchChirps(req.Context(), searchParams)
+	if err != nil {
+		log.Printf("An error occurred searching chirps: %s", err)
+		respondWithError(w, 500, errCodeInternal, "An error occurred searching chirps")
+		return
+	}
+	if len(rows) > int(limit) {
+		rows = rows[:limit]
+		setNextLinkParam(w, req, "offset", strconv.Itoa(int(offset+limit)))
+	}
+
+	chirps := make([]database.Chirp, len(rows))
+	for i, row := range rows {
+		chirps[i] = database.Chirp{
+			ID:        row.ID,
+			CreatedAt: row.CreatedAt,
+			UpdatedAt: row.UpdatedAt,
+			Body:      row.Body,
+			UserID:    row.UserID,
+			InReplyTo: row.InReplyTo,
+			RepostOf:  row.RepostOf,
+			IsRepost:  row.IsRepost,
+		}
+	}
+	decorated, err := cfg.chirpResponses(req.Context(), chirps, viewer, hasViewer)
+	if err != nil {
+		log.Printf("An error occurred getting chirp counts: %s", err)
+		respondWithError(w, 500, errCodeInternal, "An error occurred searching chirps")
+		return
+	}
+	results := make([]searchResult, len(rows))
+	for i, row := range rows {
+		results[i] = searchResult{
+			chirpResponse: decorated[i],
+			Rank:          row.Rank,
+			Highlight:     escapeHeadline(row.Headline),
+		}
+	}
+	out, err := json.Marshal(results)
+	if err != nil {
+		log.Printf("An error occurred marshalling JSON: %s", err)
+		respondWithError(w, 500, errCodeInternal, "A marshaling error occurred")
+		return
+	}
+	respondWithJSON(w, 200, out)
+}
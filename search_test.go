@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSearchTime(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{"2024-03-01T12:00:00Z", time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)},
+		{"2024-03-01T12:00:00+05:00", time.Date(2024, 3, 1, 7, 0, 0, 0, time.UTC)},
+		{"2024-03-01", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tc := range tests {
+		t.Run(tc.value, func(t *testing.T) {
+			got, err := parseSearchTime(tc.value)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			// The wall clock is what survives the cast to TIMESTAMP, so
+			// compare it rather than the instant.
+			if !got.Valid || got.Time.Location() != time.UTC || !got.Time.Equal(tc.want) {
+				t.Errorf("Expected %v, got %v", tc.want, got.Time)
+			}
+		})
+	}
+}
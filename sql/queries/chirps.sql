@@ -1,11 +1,76 @@
 -- name: CreateChirp :one
-INSERT INTO chirps(id, created_at, updated_at, body, user_id)
-VALUES ($1, $2, $3, $4, $5)
+INSERT INTO chirps(id, created_at, updated_at, body, user_id, in_reply_to, repost_of, is_repost)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
   RETURNING *;
 
 -- name: GetChirps :many
 SELECT * FROM chirps
-ORDER BY created_at ASC;
+WHERE (created_at, id) > (sqlc.arg(cursor_created_at)::timestamp, sqlc.arg(cursor_id)::uuid)
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg(page_size);
+
+-- name: GetChirpsDesc :many
+SELECT * FROM chirps
+WHERE (created_at, id) < (sqlc.arg(cursor_created_at)::timestamp, sqlc.arg(cursor_id)::uuid)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: GetChirpsByAuthor :many
+SELECT * FROM chirps
+WHERE user_id = sqlc.arg(user_id)
+  AND (created_at, id) > (sqlc.arg(cursor_created_at)::timestamp, sqlc.arg(cursor_id)::uuid)
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg(page_size);
+
+-- name: GetChirpsByAuthorDesc :many
+SELECT * FROM chirps
+WHERE user_id = sqlc.arg(user_id)
+  AND (created_at, id) < (sqlc.arg(cursor_created_at)::timestamp, sqlc.arg(cursor_id)::uuid)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
 
 -- name: GetChirp :one
 SELECT * FROM chirps WHERE id = $1;
+
+-- name: DeleteChirp :exec
+DELETE FROM chirps WHERE id = $1;
+
+-- name: GetChirpsByIDs :many
+SELECT * FROM chirps
+WHERE id = ANY(sqlc.arg(ids)::uuid[]);
+
+-- name: GetChirpAncestors :many
+WITH RECURSIVE ancestors(id, in_reply_to) AS (
+  SELECT c.id, c.in_reply_to FROM chirps c WHERE c.id = $1
+  UNION ALL
+  SELECT p.id, p.in_reply_to FROM chirps p
+  INNER JOIN ancestors a ON p.id = a.in_reply_to
+)
+SELECT chirps.* FROM chirps
+WHERE chirps.id IN (SELECT ancestors.id FROM ancestors)
+  AND chirps.id <> $1
+ORDER BY chirps.created_at ASC, chirps.id ASC;
+
+-- name: GetReplies :many
+SELECT * FROM chirps
+WHERE in_reply_to = sqlc.arg(parent_id)
+  AND (created_at, id) > (sqlc.arg(cursor_created_at)::timestamp, sqlc.arg(cursor_id)::uuid)
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg(page_size);
+
+-- name: GetReplyCounts :many
+SELECT in_reply_to AS chirp_id, COUNT(*) AS reply_count FROM chirps
+WHERE in_reply_to = ANY(sqlc.arg(chirp_ids)::uuid[])
+GROUP BY in_reply_to;
+
+-- name: SearchChirps :many
+SELECT chirps.*,
+  ts_rank(chirps.search_vector, query)::real AS rank,
+  ts_headline('english', chirps.body, query, 'StartSel=<mark>, StopSel=</mark>, HighlightAll=true')::text AS headline
+FROM chirps, websearch_to_tsquery('english', sqlc.arg(query)) query
+WHERE chirps.search_vector @@ query
+  AND (sqlc.narg(author_id)::uuid IS NULL OR chirps.user_id = sqlc.narg(author_id))
+  AND (sqlc.narg(since)::timestamp IS NULL OR chirps.created_at >= sqlc.narg(since))
+  AND (sqlc.narg(until)::timestamp IS NULL OR chirps.created_at < sqlc.narg(until))
+ORDER BY rank DESC, chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg(page_size) OFFSET sqlc.arg(page_offset);
@@ -0,0 +1,8 @@
+-- name: AddChirpMedia :exec
+INSERT INTO chirp_media(id, chirp_id, position, storage_key, content_type, created_at)
+VALUES ($1, $2, $3, $4, $5, $6);
+
+-- name: GetChirpMedia :many
+SELECT * FROM chirp_media
+WHERE chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[])
+ORDER BY chirp_id, position;
@@ -0,0 +1,15 @@
+-- name: CreatePasswordReset :exec
+INSERT INTO password_resets(token_hash, user_id, created_at, expires_at)
+VALUES ($1, $2, $3, $4);
+
+-- name: DeletePasswordResets :exec
+DELETE FROM password_resets
+WHERE user_id = $1 AND used_at IS NULL;
+
+-- name: UsePasswordReset :one
+UPDATE password_resets
+SET used_at = sqlc.arg(now)
+WHERE token_hash = sqlc.arg(token_hash)
+  AND used_at IS NULL
+  AND expires_at > sqlc.arg(now)
+  RETURNING user_id;
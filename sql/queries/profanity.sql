@@ -0,0 +1,20 @@
+-- name: GetBannedWords :many
+SELECT word FROM banned_words
+ORDER BY word ASC;
+
+-- name: AddBannedWord :execrows
+INSERT INTO banned_words(word, created_at)
+VALUES ($1, $2)
+ON CONFLICT (word) DO NOTHING;
+
+-- name: DeleteBannedWord :execrows
+DELETE FROM banned_words WHERE word = $1;
+
+-- name: FlagChirp :exec
+INSERT INTO flagged_chirps(chirp_id, created_at, words)
+VALUES ($1, $2, $3);
+
+-- name: GetFlaggedChirps :many
+SELECT chirps.*, flagged_chirps.words FROM chirps
+INNER JOIN flagged_chirps ON flagged_chirps.chirp_id = chirps.id
+ORDER BY flagged_chirps.created_at ASC;
@@ -0,0 +1,12 @@
+-- name: CreateRecoveryCode :exec
+INSERT INTO recovery_codes(user_id, code_hash, created_at)
+VALUES ($1, $2, $3);
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM recovery_codes
+WHERE user_id = $1;
+
+-- name: UseRecoveryCode :execrows
+UPDATE recovery_codes
+SET used_at = $1
+WHERE user_id = $2 AND code_hash = $3 AND used_at IS NULL;
@@ -1,13 +1,27 @@
 -- name: CreateRefreshToken :one
-INSERT INTO refresh_tokens(token, created_at, updated_at, user_id, expires_at)
-VALUES ($1, $2, $3, $4, $5)
+INSERT INTO refresh_tokens(token, created_at, updated_at, user_id, expires_at, family)
+VALUES ($1, $2, $3, $4, $5, $6)
   RETURNING *;
 
+-- name: GetRefreshToken :one
+SELECT * FROM refresh_tokens
+WHERE token = $1;
+
 -- name: RevokeToken :exec
 UPDATE refresh_tokens
 SET updated_at = $1, revoked_at = $2
 WHERE token = $3;
 
+-- name: RotateRefreshToken :execrows
+UPDATE refresh_tokens
+SET updated_at = $1, revoked_at = $2, replaced_by = $3
+WHERE token = $4 AND revoked_at IS NULL;
+
+-- name: RevokeTokenFamily :exec
+UPDATE refresh_tokens
+SET updated_at = $1, revoked_at = $2
+WHERE family = $3 AND revoked_at IS NULL;
+
 -- name: GetTokenByUserID :one
 SELECT refresh_tokens.token FROM refresh_tokens
 WHERE refresh_tokens.user_id = (
@@ -17,9 +31,13 @@ WHERE refresh_tokens.user_id = (
 );
 
 -- name: GetUserFromRefreshToken :one
-SELECT * FROM users
-WHERE users.id = (
-  SELECT user_id FROM refresh_tokens
-  INNER JOIN users on user_id = users.id
-  WHERE refresh_tokens.token = $1
-);
+SELECT users.* FROM users
+INNER JOIN refresh_tokens on refresh_tokens.user_id = users.id
+WHERE refresh_tokens.token = $1
+  AND refresh_tokens.revoked_at IS NULL
+  AND refresh_tokens.expires_at > NOW();
+
+-- name: RevokeUserTokens :exec
+UPDATE refresh_tokens
+SET updated_at = $1, revoked_at = $2
+WHERE user_id = $3 AND revoked_at IS NULL;
@@ -0,0 +1,37 @@
+-- name: AddChirpHashtag :exec
+INSERT INTO chirp_hashtags(chirp_id, tag)
+VALUES ($1, $2)
+ON CONFLICT (chirp_id, tag) DO NOTHING;
+
+-- name: AddChirpMention :execrows
+INSERT INTO chirp_mentions(chirp_id, user_id)
+SELECT sqlc.arg(chirp_id), users.id FROM users
+WHERE lower(users.handle) = lower(sqlc.arg(handle))
+ON CONFLICT (chirp_id, user_id) DO NOTHING;
+
+-- name: GetChirpsByHashtag :many
+SELECT chirps.* FROM chirps
+INNER JOIN chirp_hashtags ON chirp_hashtags.chirp_id = chirps.id
+WHERE chirp_hashtags.tag = sqlc.arg(tag)
+  AND (chirps.created_at, chirps.id) < (sqlc.arg(cursor_created_at)::timestamp, sqlc.arg(cursor_id)::uuid)
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: GetMentions :many
+SELECT chirps.* FROM chirps
+INNER JOIN chirp_mentions ON chirp_mentions.chirp_id = chirps.id
+WHERE chirp_mentions.user_id = sqlc.arg(user_id)
+  AND (chirps.created_at, chirps.id) < (sqlc.arg(cursor_created_at)::timestamp, sqlc.arg(cursor_id)::uuid)
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: GetTrendingHashtags :many
+SELECT chirp_hashtags.tag,
+  COUNT(*) AS uses,
+  SUM(EXP(-LN(2) * EXTRACT(EPOCH FROM (sqlc.arg(now)::timestamp - chirps.created_at)) / sqlc.arg(half_life_seconds)::float8))::float8 AS score
+FROM chirp_hashtags
+INNER JOIN chirps ON chirps.id = chirp_hashtags.chirp_id
+WHERE chirps.created_at >= sqlc.arg(since)::timestamp
+GROUP BY chirp_hashtags.tag
+ORDER BY score DESC, uses DESC, chirp_hashtags.tag ASC
+LIMIT sqlc.arg(max_tags);
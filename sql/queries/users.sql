@@ -1,6 +1,6 @@
 -- name: CreateUser :one
-INSERT INTO users(id, created_at, updated_at, email, hashed_password)
-VALUES ($1, $2, $3, $4, $5)
+INSERT INTO users(id, created_at, updated_at, email, hashed_password, handle)
+VALUES ($1, $2, $3, $4, $5, $6)
   RETURNING *;
 
 -- name: DeleteUsers :exec
@@ -9,3 +9,88 @@ DELETE FROM users;
 -- name: GetUserByEmail :one
 SELECT * FROM users
 WHERE email = $1;
+
+-- name: GetChirpAuthors :many
+SELECT id, handle, display_name, avatar_url_48, avatar_url_128, avatar_url_400 FROM users
+WHERE id = ANY(sqlc.arg(user_ids)::uuid[]);
+
+-- name: GetUserByID :one
+SELECT * FROM users
+WHERE id = $1;
+
+-- name: GetUserProfile :one
+SELECT users.id, users.created_at, users.handle, users.display_name, users.bio,
+  users.avatar_url_48, users.avatar_url_128, users.avatar_url_400,
+  (SELECT COUNT(*) FROM chirps WHERE chirps.user_id = users.id) AS chirp_count,
+  (SELECT COUNT(*) FROM follows WHERE follows.followee_id = users.id) AS follower_count,
+  (SELECT COUNT(*) FROM follows WHERE follows.follower_id = users.id) AS following_count
+FROM users
+WHERE lower(users.handle) = lower(sqlc.arg(handle));
+
+-- name: RecordLoginFailure :one
+UPDATE users
+SET failed_logins = CASE
+    WHEN last_failed_login_at > sqlc.arg(reset_before)::timestamp THEN failed_logins + 1
+    ELSE 1
+  END,
+  last_failed_login_at = sqlc.arg(now)::timestamp
+WHERE id = sqlc.arg(id)
+RETURNING failed_logins, last_failed_login_at, locked_until;
+
+-- name: LockUser :exec
+UPDATE users
+SET failed_logins = 0, locked_until = $2
+WHERE id = $1;
+
+-- name: ResetLoginFailures :exec
+UPDATE users
+SET failed_logins = 0, last_failed_login_at = NULL, locked_until = NULL
+WHERE id = $1;
+
+-- name: SetEmailVerified :exec
+UPDATE users
+SET email_verified = true, updated_at = $2
+WHERE id = $1;
+
+-- name: SetTOTPSecret :exec
+UPDATE users
+SET totp_secret = $2, totp_enabled = false, updated_at = $3
+WHERE id = $1;
+
+-- name: EnableTOTP :exec
+UPDATE users
+SET totp_enabled = true, totp_last_step = $2, updated_at = $3
+WHERE id = $1;
+
+-- name: DisableTOTP :exec
+UPDATE users
+SET totp_secret = '', totp_enabled = false, totp_last_step = 0, updated_at = $2
+WHERE id = $1;
+
+-- name: UseTOTPStep :execrows
+UPDATE users
+SET totp_last_step = $2
+WHERE id = $1 AND totp_last_step < $2;
+
+-- name: SetUserPassword :exec
+UPDATE users
+SET hashed_password = $2, updated_at = $3
+WHERE id = $1;
+
+-- name: UpdateUser :one
+UPDATE users
+SET email = $2, hashed_password = $3, updated_at = $4,
+  handle = $5, display_name = $6, bio = $7, email_verified = $8
+WHERE id = $1
+  RETURNING *;
+
+-- name: UpgradeToChirpyRed :execrows
+UPDATE users
+SET is_chirpy_red = TRUE, updated_at = $2
+WHERE id = $1;
+
+-- name: UpdateUserAvatar :one
+UPDATE users
+SET avatar_url_48 = $2, avatar_url_128 = $3, avatar_url_400 = $4, updated_at = $5
+WHERE id = $1
+  RETURNING *;
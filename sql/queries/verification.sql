@@ -0,0 +1,16 @@
+-- name: CreateEmailVerification :exec
+INSERT INTO email_verifications(id, user_id, email, created_at, expires_at)
+VALUES ($1, $2, $3, $4, $5);
+
+-- name: DeleteEmailVerifications :exec
+DELETE FROM email_verifications
+WHERE user_id = $1 AND used_at IS NULL;
+
+-- name: UseEmailVerification :execrows
+UPDATE email_verifications
+SET used_at = sqlc.arg(now)
+WHERE email_verifications.id = sqlc.arg(id)
+  AND email_verifications.user_id = sqlc.arg(user_id)
+  AND used_at IS NULL
+  AND expires_at > sqlc.arg(now)
+  AND email_verifications.email = (SELECT users.email FROM users WHERE users.id = sqlc.arg(user_id));
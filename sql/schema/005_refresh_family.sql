@@ -0,0 +1,11 @@
+-- +goose Up
+ALTER TABLE refresh_tokens
+ADD COLUMN family UUID NOT NULL
+DEFAULT gen_random_uuid();
+
+CREATE INDEX refresh_tokens_family_idx ON refresh_tokens(family);
+
+-- +goose Down
+DROP INDEX refresh_tokens_family_idx;
+ALTER TABLE refresh_tokens
+DROP COLUMN family;
@@ -0,0 +1,18 @@
+-- +goose Up
+CREATE TABLE banned_words(
+  word TEXT PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL
+);
+
+INSERT INTO banned_words(word, created_at)
+VALUES ('kerfuffle', NOW()), ('sharbert', NOW()), ('fornax', NOW());
+
+CREATE TABLE flagged_chirps(
+  chirp_id UUID PRIMARY KEY references chirps(id) ON DELETE CASCADE,
+  created_at TIMESTAMP NOT NULL,
+  words TEXT NOT NULL
+);
+
+-- +goose Down
+DROP TABLE flagged_chirps;
+DROP TABLE banned_words;
@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE TABLE chirp_likes(
+  user_id UUID NOT NULL references users(id) ON DELETE CASCADE,
+  chirp_id UUID NOT NULL references chirps(id) ON DELETE CASCADE,
+  created_at TIMESTAMP NOT NULL,
+  unique(user_id, chirp_id)
+);
+
+CREATE INDEX chirp_likes_chirp_id_idx ON chirp_likes(chirp_id);
+
+-- +goose Down
+DROP TABLE chirp_likes;
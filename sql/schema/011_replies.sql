@@ -0,0 +1,10 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD COLUMN in_reply_to UUID references chirps(id) ON DELETE SET NULL;
+
+CREATE INDEX chirps_in_reply_to_created_at_id_idx ON chirps(in_reply_to, created_at, id);
+
+-- +goose Down
+DROP INDEX chirps_in_reply_to_created_at_id_idx;
+ALTER TABLE chirps
+DROP COLUMN in_reply_to;
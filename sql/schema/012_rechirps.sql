@@ -0,0 +1,12 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD COLUMN repost_of UUID references chirps(id) ON DELETE SET NULL,
+ADD COLUMN is_repost BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX chirps_repost_of_idx ON chirps(repost_of);
+
+-- +goose Down
+DROP INDEX chirps_repost_of_idx;
+ALTER TABLE chirps
+DROP COLUMN is_repost,
+DROP COLUMN repost_of;
@@ -0,0 +1,8 @@
+-- +goose Up
+-- The tsvector is indexed as an expression rather than stored in a column so
+-- that it does not become part of database.Chirp and every chirp response.
+CREATE INDEX chirps_body_search_idx ON chirps
+USING GIN (to_tsvector('english', body));
+
+-- +goose Down
+DROP INDEX chirps_body_search_idx;
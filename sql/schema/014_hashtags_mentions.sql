@@ -0,0 +1,28 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN handle TEXT;
+
+CREATE UNIQUE INDEX users_handle_lower_idx ON users(lower(handle));
+
+CREATE TABLE chirp_hashtags(
+  chirp_id UUID NOT NULL references chirps(id) ON DELETE CASCADE,
+  tag TEXT NOT NULL,
+  PRIMARY KEY (chirp_id, tag)
+);
+
+CREATE INDEX chirp_hashtags_tag_idx ON chirp_hashtags(tag);
+
+CREATE TABLE chirp_mentions(
+  chirp_id UUID NOT NULL references chirps(id) ON DELETE CASCADE,
+  user_id UUID NOT NULL references users(id) ON DELETE CASCADE,
+  PRIMARY KEY (chirp_id, user_id)
+);
+
+CREATE INDEX chirp_mentions_user_id_idx ON chirp_mentions(user_id);
+
+-- +goose Down
+DROP TABLE chirp_mentions;
+DROP TABLE chirp_hashtags;
+DROP INDEX users_handle_lower_idx;
+ALTER TABLE users
+DROP COLUMN handle;
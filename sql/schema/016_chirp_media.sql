@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE chirp_media(
+  id UUID PRIMARY KEY,
+  chirp_id UUID NOT NULL references chirps(id) ON DELETE CASCADE,
+  position INTEGER NOT NULL,
+  storage_key TEXT NOT NULL,
+  content_type TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  unique(chirp_id, position)
+);
+
+-- +goose Down
+DROP TABLE chirp_media;
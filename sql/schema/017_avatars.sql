@@ -0,0 +1,11 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN avatar_url_48 TEXT NOT NULL DEFAULT '',
+ADD COLUMN avatar_url_128 TEXT NOT NULL DEFAULT '',
+ADD COLUMN avatar_url_400 TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE users
+DROP COLUMN avatar_url_400,
+DROP COLUMN avatar_url_128,
+DROP COLUMN avatar_url_48;
@@ -0,0 +1,11 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN failed_logins INTEGER NOT NULL DEFAULT 0,
+ADD COLUMN last_failed_login_at TIMESTAMP,
+ADD COLUMN locked_until TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE users
+DROP COLUMN locked_until,
+DROP COLUMN last_failed_login_at,
+DROP COLUMN failed_logins;
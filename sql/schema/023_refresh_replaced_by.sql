@@ -0,0 +1,9 @@
+-- +goose Up
+-- replaced_by is set when a token is rotated, which is what tells a reused
+-- token apart from one that was simply revoked.
+ALTER TABLE refresh_tokens
+ADD COLUMN replaced_by TEXT;
+
+-- +goose Down
+ALTER TABLE refresh_tokens
+DROP COLUMN replaced_by;
@@ -0,0 +1,16 @@
+-- +goose Up
+-- A user can rechirp a chirp without comment only once.  Keep the first of
+-- any duplicates already stored so the index can be built.
+DELETE FROM chirps AS dup
+USING chirps AS first
+WHERE dup.is_repost AND dup.body = ''
+  AND first.is_repost AND first.body = ''
+  AND dup.user_id = first.user_id
+  AND dup.repost_of = first.repost_of
+  AND (dup.created_at, dup.id) > (first.created_at, first.id);
+
+CREATE UNIQUE INDEX chirps_plain_rechirp_idx ON chirps(user_id, repost_of)
+WHERE is_repost AND body = '';
+
+-- +goose Down
+DROP INDEX chirps_plain_rechirp_idx;
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/interyx/chirpy/internal/database"
+	"github.com/interyx/chirpy/internal/trending"
+)
+
+const (
+	defaultTrendingLimit = 10
+	// maxTrendingTags is how many tags are kept per window in the cache.
+	maxTrendingTags = 50
+)
+
+// loadTrending is the trending.Loader backed by the chirp_hashtags table.
+func (cfg *apiConfig) loadTrending(ctx context.Context, window trending.Window, now time.Time) ([]trending.Tag, error) {
+	rows, err := cfg.db.GetTrendingHashtags(ctx, database.GetTrendingHashtagsParams{
+		Now:             now,
+		HalfLifeSeconds: window.HalfLife.Seconds(),
+		Since:           now.Add(-window.Span),
+		MaxTags:         maxTrendingTags,
+	})
+	if err != nil {
+		return nil, err
+	}
+	tags := make([]trending.Tag, len(rows))
+	for i, row := range rows {
+		tags[i] = trending.Tag{
+			Tag:   row.Tag,
+			Uses:  row.Uses,
+			Score: row.Score,
+		}
+	}
+	return tags, nil
+}
+
+func (cfg *apiConfig) trendingHandler(w http.ResponseWriter, req *http.Request) {
+	type outerface struct {
+		Window    string         `json:"window"`
+		UpdatedAt *time.Time     `json:"updated_at"`
+		Tags      []trending.Tag `json:"tags"`
+	}
+	name := req.URL.Query().Get("window")
+	if name == "" {
+		window, _ := cfg.trending.Default()
+		name = window.Name
+	}
+	if _, ok := cfg.trending.Window(name); !ok {
+		respondWithError(w, 400, errCodeInvalidWindow, fmt.Sprintf("Unknown trending window %q", name))
+		return
+	}
+	limit := defaultTrendingLimit
+	if param := req.URL.Query().Get("limit"); param != "" {
+		n, err := strconv.Atoi(param)
+		if err != nil || n < 1 || n > maxTrendingTags {
+			msg := fmt.Sprintf("limit must be between 1 and %d", maxTrendingTags)
+			respondWithError(w, 400, errCodeInvalidPagination, msg)
+			return
+		}
+		limit = n
+	}
+
+	tags, updatedAt := cfg.trending.Get(name)
+	if len(tags) > limit {
+		tags = tags[:limit]
+	}
+	data := outerface{
+		Window: name,
+		Tags:   tags,
+	}
+	if data.Tags == nil {
+		data.Tags = []trending.Tag{}
+	}
+	if !updatedAt.IsZero() {
+		data.UpdatedAt = &updatedAt
+	}
+	out, err := json.Marshal(data)
+	if err != nil {
+		respondWithError(w, 500, errCodeInternal, "A marshaling error occurred")
+		return
+	}
+	respondWithJSON(w, 200, out)
+}
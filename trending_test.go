@@ -0,0 +1,31 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/interyx/chirpy/internal/trending"
+)
+
+func TestTrendingDefaultWindow(t *testing.T) {
+	windows, err := trending.ParseWindows("1h,7d")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	cfg := &apiConfig{trending: trending.NewCache(windows, nil)}
+	rec := httptest.NewRecorder()
+	cfg.trendingHandler(rec, httptest.NewRequest("GET", "/api/trending", nil))
+	if rec.Code != 200 {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var got struct {
+		Window string `json:"window"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got.Window != "1h" {
+		t.Errorf("Expected the first configured window 1h, got %q", got.Window)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/interyx/chirpy/internal/auth"
+	"github.com/interyx/chirpy/internal/database"
+	"github.com/interyx/chirpy/internal/mailer"
+)
+
+const emailTokenLifetime = 24 * time.Hour
+
+// validEmail accepts a bare address such as someone@example.com, without
+// a display name.
+func validEmail(email string) bool {
+	addr, err := mail.ParseAddress(email)
+	return err == nil && addr.Address == email
+}
+
+// sendVerification mails the user a link that confirms their current
+// email.  Links sent earlier stop working.
+func (cfg *apiConfig) sendVerification(ctx context.Context, user database.User) error {
+	err := cfg.db.DeleteEmailVerifications(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	tokenID := uuid.New()
+	now := time.Now()
+	err = cfg.db.CreateEmailVerification(ctx, database.CreateEmailVerificationParams{
+		ID:        tokenID,
+		UserID:    user.ID,
+		Email:     user.Email,
+		CreatedAt: now,
+		ExpiresAt: now.Add(emailTokenLifetime),
+	})
+	if err != nil {
+		return err
+	}
+	token, err := auth.MakeEmailToken(user.ID, tokenID, cfg.signJWT, emailTokenLifetime)
+	if err != nil {
+		return err
+	}
+	link := cfg.publicURL + "/api/verify?token=" + url.QueryEscape(token)
+	return cfg.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Confirm your Chirpy email address",
+		Body: fmt.Sprintf("Welcome to Chirpy!\n\nOpen this link within %d hours to confirm your email address:\n\n%s\n\n"+
+			"If you did not sign up for Chirpy, you can ignore this message.\n", int(emailTokenLifetime.Hours()), link),
+	})
+}
+
+// requireVerified writes a 403 and returns false when the user has not
+// confirmed their email yet.
+func (cfg *apiConfig) requireVerified(w http.ResponseWriter, req *http.Request, userID uuid.UUID) bool {
+	user, err := cfg.db.GetUserByID(req.Context(), userID)
+	if err != nil {
+		log.Printf("An error occurred retrieving the user: %s", err)
+		respondWithError(w, 500, errCodeInternal, "An error occurred retrieving the user")
+		return false
+	}
+	if !user.EmailVerified {
+		respondWithError(w, 403, errCodeEmailNotVerified, "Confirm your email address before chirping")
+		return false
+	}
+	return true
+}
+
+func (cfg *apiConfig) verifyEmailHandler(w http.ResponseWriter, req *http.Request) {
+	userID, tokenID, err := auth.ValidateEmailToken(req.URL.Query().Get("token"), cfg.signJWT)
+	if err != nil {
+		respondWithError(w, 400, errCodeInvalidEmailToken, "The verification link is invalid or has expired")
+		return
+	}
+
+	tx, err := cfg.sqlDB.BeginTx(req.Context(), nil)
+	if err != nil {
+		log.Printf("An error occurred starting a transaction: %s", err)
+		respondWithError(w, 500, errCodeInternal, "An error occurred verifying the email")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	// The token is single-use, and only confirms the address it was sent
+	// to: changing email in the meantime leaves nothing to update.
+	now := time.Now()
+	rows, err := qtx.UseEmailVerification(req.Context(), database.UseEmailVerificationParams{
+		Now:    now,
+		ID:     tokenID,
+		UserID: userID,
+	})
+	if err == nil && rows == 0 {
+		respondWithError(w, 400, errCodeInvalidEmailToken, "The verification link is invalid or has expired")
+		return
+	}
+	if err == nil {
+		err = qtx.SetEmailVerified(req.Context(), database.SetEmailVerifiedParams{
+			ID:        userID,
+			UpdatedAt: now,
+		})
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Printf("An error occurred verifying the email: %s", err)
+		respondWithError(w, 500, errCodeInternal, "An error occurred verifying the email")
+		return
+	}
+	respondWithJSON(w, 200, []byte(`{"email_verified":true}`))
+}
+
+func (cfg *apiConfig) resendVerificationHandler(w http.ResponseWriter, req *http.Request) {
+	userID, ok := cfg.authenticate(w, req)
+	if !ok {
+		return
+	}
+	user, err := cfg.db.GetUserByID(req.Context(), userID)
+	if err != nil {
+		log.Printf("An error occurred retrieving the user: %s", err)
+		respondWithError(w, 500, errCodeInternal, "An error occurred retrieving the user")
+		return
+	}
+	if user.EmailVerified {
+		respondWithError(w, 409, errCodeAlreadyVerified, "This email address is already confirmed")
+		return
+	}
+	err = cfg.sendVerification(req.Context(), user)
+	if errors.Is(err, mailer.ErrInvalidHeader) {
+		respondWithError(w, 400, errCodeInvalidEmail, "The email address on this account cannot receive mail")
+		return
+	}
+	if err != nil {
+		log.Printf("An error occurred sending the verification email: %s", err)
+		respondWithError(w, 500, errCodeInternal, "An error occurred sending the verification email")
+		return
+	}
+	w.WriteHeader(204)
+}